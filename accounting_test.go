@@ -0,0 +1,167 @@
+package grpc_pool
+
+import (
+	"context"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+// closeTracker report clients closed more than once by the pool
+type closeTracker struct {
+	mu     sync.Mutex
+	closed map[*IdleClient]int
+}
+
+func (ct *closeTracker) onClose(c *IdleClient) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	ct.closed[c]++
+}
+
+func (ct *closeTracker) check(t *testing.T) {
+	ct.mu.Lock()
+	defer ct.mu.Unlock()
+
+	for c, n := range ct.closed {
+		if n > 1 {
+			t.Errorf("client %v closed %v times", c.id, n)
+		}
+	}
+}
+
+// checkAccounting check count match the clients held by p
+func checkAccounting(t *testing.T, p *GRpcClientPool) {
+	p.Lock()
+	defer p.Unlock()
+
+	idle := p.idleClients()
+	if p.count != len(idle)+len(p.out)+len(p.dialing) {
+		t.Errorf("count[%v] != idle[%v] + out[%v] + dialing[%v]", p.count, len(idle), len(p.out), len(p.dialing))
+	}
+	if p.count < 0 || (p.maxCount > 0 && p.count > p.maxCount) {
+		t.Errorf("count[%v] out of [0, %v]", p.count, p.maxCount)
+	}
+
+	seen := make(map[*IdleClient]bool, len(idle))
+	for _, c := range idle {
+		switch {
+		case seen[c]:
+			t.Errorf("client %v idle twice", c.id)
+		case c.closed:
+			t.Errorf("client %v idle but closed", c.id)
+		}
+		if _, ok := p.out[c]; ok {
+			t.Errorf("client %v both idle and checked out", c.id)
+		}
+		seen[c] = true
+	}
+	for c := range p.out {
+		if c.closed {
+			t.Errorf("client %v checked out but closed", c.id)
+		}
+	}
+}
+
+// TestAccountingRandomized run random operations concurrently, checking the
+// count accounting after each. Run it with -race.
+func TestAccountingRandomized(t *testing.T) {
+	for seed := int64(1); seed <= 4; seed++ {
+		ct := &closeTracker{closed: make(map[*IdleClient]int)}
+		p := newTestPool(t, newTestServer(t), 8, 20*time.Millisecond,
+			WithMaxLifetime(40*time.Millisecond), WithMaxIdle(5), WithMinIdle(3),
+			WithReaper(5*time.Millisecond), WithLeaseTimeout(30*time.Millisecond),
+			WithOnClose(ct.onClose))
+
+		var wg sync.WaitGroup
+		for g := int64(0); g < 8; g++ {
+			wg.Add(1)
+			go func(r *rand.Rand) {
+				defer wg.Done()
+				runRandomOps(t, p, r, 300)
+			}(rand.New(rand.NewSource(seed*100 + g)))
+		}
+		wg.Wait()
+
+		checkAccounting(t, p)
+		p.Release()
+		checkAccounting(t, p)
+		ct.check(t)
+		if s := p.Stats(); s.Count != 0 {
+			t.Errorf("seed %v: want count 0 after Release, got %+v", seed, s)
+		}
+	}
+}
+
+// runRandomOps run n random operations on p, including misuse as double Put
+// or DelErrorClient
+func runRandomOps(t *testing.T, p *GRpcClientPool, r *rand.Rand, n int) {
+	var held []*IdleClient
+	take := func() *IdleClient {
+		c := held[0]
+		held = held[1:]
+		return c
+	}
+
+	for i := 0; i < n; i++ {
+		switch r.Intn(12) {
+		case 0, 1:
+			if c, err := p.Get(); err == nil {
+				held = append(held, c)
+			}
+		case 2:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			if c, err := p.GetNoDial(ctx); err == nil {
+				held = append(held, c)
+			}
+			cancel()
+		case 3:
+			ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+			if c, err := p.GetWait(ctx); err == nil {
+				held = append(held, c)
+			}
+			cancel()
+		case 4, 5:
+			if len(held) > 0 {
+				c := take()
+				p.Put(c)
+				if r.Intn(4) == 0 {
+					p.Put(c)
+				}
+			}
+		case 6:
+			if len(held) > 0 {
+				c := take()
+				p.DelErrorClient(c)
+				if r.Intn(4) == 0 {
+					p.DelErrorClient(c)
+				}
+			}
+		case 7:
+			if len(held) > 0 {
+				p.Evict(held[0])
+			}
+		case 8:
+			p.Warmup(context.Background())
+		case 9:
+			if len(held) > 0 {
+				if c := take(); r.Intn(2) == 0 {
+					p.PutPoisoned(c)
+				} else {
+					p.Put(c)
+				}
+			}
+		case 10:
+			time.Sleep(time.Millisecond)
+		case 11:
+			if r.Intn(30) == 0 {
+				p.Release()
+			}
+		}
+		checkAccounting(t, p)
+	}
+
+	p.PutAll(held)
+}
@@ -0,0 +1,65 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// BoostMaxCount raise maxCount by extra for d, e.g. for a scheduled batch
+// job. Boosts overlapping stack, and each takes back only its own extra when
+// it ends: idle connections beyond maxCount are closed then, checked out ones
+// when given back. It does nothing if the pool has no maxCount, and boosts
+// left are stopped by Release.
+func (p *GRpcClientPool) BoostMaxCount(extra int, d time.Duration) {
+	if extra <= 0 || d <= 0 {
+		return
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	if p.maxCount <= 0 || p.released() {
+		return
+	}
+	p.maxCount += extra
+	p.event(EventResize, "maxCount raised to %v by %v for %v", p.maxCount, extra, d)
+	p.checkHighWater()
+	// wake up GetWait waiting for room to dial
+	p.cond.Broadcast()
+
+	var t *time.Timer
+	t = time.AfterFunc(d, func() {
+		p.Lock()
+		defer p.Unlock()
+
+		if _, ok := p.boostTimers[t]; !ok { // stopped by Release
+			return
+		}
+		delete(p.boostTimers, t)
+		p.maxCount -= extra
+		p.event(EventResize, "maxCount lowered to %v by %v", p.maxCount, extra)
+		p.shrinkIdle()
+		p.checkHighWater()
+	})
+	if p.boostTimers == nil {
+		p.boostTimers = make(map[*time.Timer]struct{})
+	}
+	p.boostTimers[t] = struct{}{}
+}
+
+// stopBoosts stop the timers ending boosts, p MUST be locked
+func (p *GRpcClientPool) stopBoosts() {
+	for t := range p.boostTimers {
+		t.Stop()
+	}
+	p.boostTimers = nil
+}
+
+// shrinkIdle close the oldest idle clients by creation while count over
+// maxCount, p MUST be locked
+func (p *GRpcClientPool) shrinkIdle() {
+	for p.count-p.overflow > p.maxCount && p.pool.size() > 0 {
+		c := p.oldestIdle()
+		p.removeIdle(c)
+		p.retire(c)
+	}
+}
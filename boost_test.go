@@ -0,0 +1,60 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBoostMaxCountOverlapping(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute)
+	p.BoostMaxCount(2, 50*time.Millisecond)
+	p.BoostMaxCount(1, 150*time.Millisecond)
+
+	var cs []*IdleClient
+	for i := 0; i < 4; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatalf("want room of both boosts, got %v", err)
+		}
+		cs = append(cs, c)
+	}
+	if _, err := p.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+
+	// the first boost takes back its own extra, closing idle clients beyond
+	p.Put(cs[0])
+	p.Put(cs[1])
+	waitUntil(t, func() bool {
+		s := p.Stats()
+		return s.MaxCount == 2 && s.Count == 2 && s.Idle == 0
+	})
+
+	waitUntil(t, func() bool { return p.Stats().MaxCount == 1 })
+	p.Put(cs[2])
+	if s := p.Stats(); s.Count != 1 || s.Idle != 0 {
+		t.Fatalf("want the client beyond maxCount closed, got %+v", s)
+	}
+	p.Put(cs[3])
+	if s := p.Stats(); s.Count != 1 || s.Idle != 1 {
+		t.Fatalf("want the client kept, got %+v", s)
+	}
+}
+
+func TestBoostMaxCountRelease(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute)
+	p.BoostMaxCount(2, 20*time.Millisecond)
+	p.Release()
+
+	p.Lock()
+	timers := len(p.boostTimers)
+	p.Unlock()
+	if timers != 0 {
+		t.Fatalf("want the timers stopped, got %v left", timers)
+	}
+
+	time.Sleep(40 * time.Millisecond)
+	if s := p.Stats(); s.MaxCount != 3 {
+		t.Fatalf("want maxCount left alone after Release, got %v", s.MaxCount)
+	}
+}
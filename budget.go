@@ -0,0 +1,113 @@
+package grpc_pool
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// Size of dial history and how long a dial duration counts for, so the
+// estimate recover after dials become fast again even if Get kept failing
+const (
+	dialHistorySize = 32
+	dialHistoryTTL  = time.Minute
+)
+
+// dialSample is the duration of a successful dial finished at end
+type dialSample struct {
+	end time.Time
+	d   time.Duration
+}
+
+// dialHistory keep recent dial durations in a ring
+type dialHistory struct {
+	samples []dialSample
+	next    int
+}
+
+// record add a dial duration, overwriting the oldest when full
+func (h *dialHistory) record(now time.Time, d time.Duration) {
+	sample := dialSample{end: now, d: d}
+	if len(h.samples) < dialHistorySize {
+		h.samples = append(h.samples, sample)
+		return
+	}
+	h.samples[h.next] = sample
+	h.next = (h.next + 1) % dialHistorySize
+}
+
+// p95 return the 95th percentile of dial durations within dialHistoryTTL,
+// 0 if none
+func (h *dialHistory) p95(now time.Time) time.Duration {
+	var ds []time.Duration
+	for _, sample := range h.samples {
+		if now.Sub(sample.end) < dialHistoryTTL {
+			ds = append(ds, sample.d)
+		}
+	}
+	if len(ds) == 0 {
+		return 0
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i] < ds[j] })
+
+	return ds[(len(ds)*95+99)/100-1]
+}
+
+// WithMaxDialLatencyBudget make Get fail fast with ERROR_BUDGET_EXCEEDED
+// rather than dial, when no idle conn is left and the p95 of recent dials
+// exceeds d or the time left before the deadline of ctx, whichever is less.
+// A dial lasts until the connection is Ready, so connections dialed lazily
+// are kicked to connect, and ones never Ready within dialHistoryTTL are not
+// counted.
+func WithMaxDialLatencyBudget(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if d > 0 {
+			p.latencyBudget = d
+			p.dialTimes = &dialHistory{}
+		}
+	}
+}
+
+// overBudget return true if a dial is estimated slower than the budget left,
+// lock must be held
+func (p *GRpcClientPool) overBudget(ctx context.Context) bool {
+	if p.dialTimes == nil {
+		return false
+	}
+
+	now := time.Now()
+	budget := p.latencyBudget
+	if deadline, ok := ctx.Deadline(); ok {
+		if left := deadline.Sub(now); left < budget {
+			budget = left
+		}
+	}
+
+	return p.dialTimes.p95(now) > budget
+}
+
+// timeDial record the dial of c started at start once c is Ready, in
+// background unless it's Ready already, p MUST be locked
+func (p *GRpcClientPool) timeDial(c *IdleClient, start time.Time) {
+	if c.conn.GetState() == connectivity.Ready {
+		now := time.Now()
+		p.dialTimes.record(now, now.Sub(start))
+		return
+	}
+
+	p.background(func() {
+		ctx, cancel := context.WithTimeout(p.ctx, dialHistoryTTL)
+		defer cancel()
+		if waitReady(ctx, c, false) != nil {
+			return
+		}
+
+		now := time.Now()
+		p.Lock()
+		p.dialTimes.record(now, now.Sub(start))
+		p.Unlock()
+	})
+}
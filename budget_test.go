@@ -0,0 +1,101 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// seedDials record n dials lasting d in the history of p
+func seedDials(p *GRpcClientPool, n int, d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	for i := 0; i < n; i++ {
+		p.dialTimes.record(time.Now(), d)
+	}
+}
+
+func dialSamples(p *GRpcClientPool) int {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.dialTimes.samples)
+}
+
+func TestDialLatencyBudget(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxDialLatencyBudget(50*time.Millisecond))
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seedDials(p, 40, 200*time.Millisecond)
+	if _, err := p.Get(); err != ERROR_BUDGET_EXCEEDED {
+		t.Fatalf("want ERROR_BUDGET_EXCEEDED, got %v", err)
+	}
+
+	// idle clients are served whatever the dial history
+	p.Put(c)
+	if c2, err := p.Get(); err != nil || c2 != c {
+		t.Fatalf("want the idle client, got %v", err)
+	}
+}
+
+func TestDialLatencyBudgetDeadline(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxDialLatencyBudget(time.Second))
+
+	seedDials(p, 20, 100*time.Millisecond)
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("want dials within the budget, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != ERROR_BUDGET_EXCEEDED {
+		t.Fatalf("want ERROR_BUDGET_EXCEEDED over the deadline, got %v", err)
+	}
+
+	// outdated dials don't count
+	p.Lock()
+	for i := range p.dialTimes.samples {
+		p.dialTimes.samples[i].end = time.Now().Add(-2 * dialHistoryTTL)
+	}
+	p.Unlock()
+	if _, err := p.GetContext(ctx); err != nil {
+		t.Fatalf("want the outdated history ignored, got %v", err)
+	}
+
+	if cfg := p.Config(); cfg.DialLatencyBudget != time.Second {
+		t.Fatalf("want the budget in Config, got %+v", cfg)
+	}
+}
+
+func TestDialLatencyUntilReady(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxDialLatencyBudget(time.Second))
+
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+	// the lazy client is kicked, and the dial recorded once Ready
+	waitUntil(t, func() bool { return dialSamples(p) == 1 })
+}
+
+func TestDialLatencyNeverReady(t *testing.T) {
+	srv := newTestServer(t)
+	down := func(context.Context, string) (net.Conn, error) { return nil, errors.New("server down") }
+	p := newTestPool(t, srv, 5, time.Minute, WithMaxDialLatencyBudget(time.Second),
+		WithDialOptionsFunc(srv.DialOptionsFunc()), WithDialOptions(grpc.WithContextDialer(down)))
+
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if n := dialSamples(p); n != 0 {
+		t.Fatalf("want a dial never Ready not recorded, got %v samples", n)
+	}
+}
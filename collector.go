@@ -0,0 +1,45 @@
+package grpc_pool
+
+// Collector receive events of the pool, e.g. to export metrics. It's called
+// with the pool locked, so MUST NOT call back into the pool.
+type Collector interface {
+	// OnClose is called after a connection closed by the pool, err is
+	// returned by ClientConn.Close
+	OnClose(err error)
+}
+
+// ShutdownCollector is optionally implemented by a Collector to learn about
+// connections closed by CloseGracefully, which are intentional, so OnClose
+// isn't called for them
+type ShutdownCollector interface {
+	OnShutdownClose()
+}
+
+// WithCollector set the collector of the pool, nil means none
+func WithCollector(c Collector) Option {
+	return func(p *GRpcClientPool) {
+		p.collector = c
+	}
+}
+
+// closeClient close c, reporting the close error to the logger and collector.
+// Closes on graceful shutdown are reported as intentional, never as errors.
+func (p *GRpcClientPool) closeClient(c *IdleClient) {
+	if p.onClose != nil {
+		p.onClose(c)
+	}
+	err := c.close()
+	p.noteFDFreed()
+	if p.closing.Load() {
+		if sc, ok := p.collector.(ShutdownCollector); ok {
+			sc.OnShutdownClose()
+		}
+		return
+	}
+	if err != nil {
+		p.logClient(c, "grpc_pool: close client: %v", err)
+	}
+	if p.collector != nil {
+		p.collector.OnClose(err)
+	}
+}
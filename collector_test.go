@@ -0,0 +1,59 @@
+package grpc_pool
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+)
+
+// closeCollector record close errors, the pool calls it locked
+type closeCollector struct {
+	errs      []error
+	shutdowns int
+}
+
+func (c *closeCollector) OnClose(err error) { c.errs = append(c.errs, err) }
+
+func (c *closeCollector) OnShutdownClose() { c.shutdowns++ }
+
+func TestCloseError(t *testing.T) {
+	var logs logBuffer
+	col := &closeCollector{}
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithLogger(&logs), WithCollector(col))
+
+	c, _ := p.Get()
+	c2, _ := p.Get()
+	// closing again fail
+	c.GetConn().Close()
+	p.DelErrorClient(c)
+	p.DelErrorClient(c2)
+
+	p.Lock()
+	errs := col.errs
+	p.Unlock()
+	if len(errs) != 2 || errs[0] == nil || errs[1] != nil {
+		t.Fatalf("want the close error of c only, got %v", errs)
+	}
+	if !strings.Contains(logs.String(), "close client") {
+		t.Fatalf("want the close error logged, got %q", logs.String())
+	}
+	if !strings.Contains(logs.String(), " id="+c.ID()) {
+		t.Fatalf("want the id of c logged, got %q", logs.String())
+	}
+}
+
+func TestCloseGracefullyShutdownCollector(t *testing.T) {
+	col := &closeCollector{}
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithCollector(col))
+
+	c, _ := p.Get()
+	p.Put(c)
+	if err := p.CloseGracefully(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if col.shutdowns != 1 || len(col.errs) != 0 {
+		t.Fatalf("want the close reported as shutdown, got %+v", col)
+	}
+}
@@ -0,0 +1,364 @@
+package grpc_pool
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Config holds the tunables of GRpcClientPool, so a pool can be built from
+// config files or environment. Zero values mean the same as not setting the
+// corresponding option.
+type Config struct {
+	// Max size of pool, 0 means no limit
+	MaxCount int `json:"max_count" yaml:"max_count"`
+	// Idle duration, client will be remove after idleTimeout from last used
+	// time, 0 means no idle timeout
+	IdleTimeout time.Duration `json:"idle_timeout" yaml:"idle_timeout"`
+
+	// See WithMinIdle, WithMaxIdle
+	MinIdle int `json:"min_idle" yaml:"min_idle"`
+	MaxIdle int `json:"max_idle" yaml:"max_idle"`
+
+	// See WithMaxLifetime, WithReaper, WithStaleScanInterval, WithLeaseTimeout
+	MaxLifetime       time.Duration `json:"max_lifetime" yaml:"max_lifetime"`
+	ReapInterval      time.Duration `json:"reap_interval" yaml:"reap_interval"`
+	StaleScanInterval time.Duration `json:"stale_scan_interval" yaml:"stale_scan_interval"`
+	LeaseTimeout      time.Duration `json:"lease_timeout" yaml:"lease_timeout"`
+
+	// See WithMaxIdleReuses, WithServerMaxConnAge, WithServerConnAgeMargin
+	MaxIdleReuses       int           `json:"max_idle_reuses" yaml:"max_idle_reuses"`
+	ServerMaxConnAge    time.Duration `json:"server_max_conn_age" yaml:"server_max_conn_age"`
+	ServerConnAgeMargin time.Duration `json:"server_conn_age_margin" yaml:"server_conn_age_margin"`
+
+	// See WithDialTimeout, WithDialRateLimit, WithMaxDialLatencyBudget
+	DialTimeout       time.Duration `json:"dial_timeout" yaml:"dial_timeout"`
+	DialRateLimit     int           `json:"dial_rate_limit" yaml:"dial_rate_limit"`
+	DialRateLimitPer  time.Duration `json:"dial_rate_limit_per" yaml:"dial_rate_limit_per"`
+	DialLatencyBudget time.Duration `json:"dial_latency_budget" yaml:"dial_latency_budget"`
+
+	// See WithMaxGetBlock
+	MaxGetBlock time.Duration `json:"max_get_block" yaml:"max_get_block"`
+
+	// See WithMaxConcurrentStreams, WithMaxConcurrentDials
+	MaxConcurrentStreams int `json:"max_concurrent_streams" yaml:"max_concurrent_streams"`
+	MaxConcurrentDials   int `json:"max_concurrent_dials" yaml:"max_concurrent_dials"`
+
+	// See WithLoadShedding, WithStreamsPerConn, WithOverflow
+	MaxWaiters     int `json:"max_waiters" yaml:"max_waiters"`
+	StreamsPerConn int `json:"streams_per_conn" yaml:"streams_per_conn"`
+	MaxOverflow    int `json:"max_overflow" yaml:"max_overflow"`
+
+	// See WithPauseBlocks, WithReviveIdle, WithCapturePeer
+	PauseBlocks bool `json:"pause_blocks" yaml:"pause_blocks"`
+	ReviveIdle  bool `json:"revive_idle" yaml:"revive_idle"`
+	CapturePeer bool `json:"capture_peer" yaml:"capture_peer"`
+
+	// See WithKeepWarm, WithIdleSelect, WithPreferFresh, WithIdleHeap
+	KeepWarm    time.Duration `json:"keep_warm" yaml:"keep_warm"`
+	IdleSelect  IdleSelect    `json:"idle_select" yaml:"idle_select"`
+	PreferFresh bool          `json:"prefer_fresh" yaml:"prefer_fresh"`
+	IdleHeap    bool          `json:"idle_heap" yaml:"idle_heap"`
+
+	// See WithReconnectJitter, which set it for every pool of a MapPool
+	ReconnectJitter time.Duration `json:"reconnect_jitter" yaml:"reconnect_jitter"`
+
+	// See WithKeepaliveTime, WithKeepaliveTimeout,
+	// WithKeepalivePermitWithoutStream
+	KeepaliveTime                time.Duration `json:"keepalive_time" yaml:"keepalive_time"`
+	KeepaliveTimeout             time.Duration `json:"keepalive_timeout" yaml:"keepalive_timeout"`
+	KeepalivePermitWithoutStream bool          `json:"keepalive_permit_without_stream" yaml:"keepalive_permit_without_stream"`
+
+	// See WithRetryBudget
+	RetryBudgetRatio     float64 `json:"retry_budget_ratio" yaml:"retry_budget_ratio"`
+	RetryBudgetMinPerSec int     `json:"retry_budget_min_per_sec" yaml:"retry_budget_min_per_sec"`
+
+	// See WithNegativeCacheTTL, WithEventBuffer, WithEagerDial
+	NegativeCacheTTL time.Duration `json:"negative_cache_ttl" yaml:"negative_cache_ttl"`
+	EventBuffer      int           `json:"event_buffer" yaml:"event_buffer"`
+	EagerDial        int           `json:"eager_dial" yaml:"eager_dial"`
+
+	// Threshold of WithHighWaterCallback, it takes effect with a callback set
+	// by opts of NewGRpcClientPoolFromConfig, whose threshold overrides it
+	HighWaterThreshold float64 `json:"high_water_threshold" yaml:"high_water_threshold"`
+
+	// See WithAggressiveDial, WithStateWatching, WithStatsTracking
+	AggressiveDial bool `json:"aggressive_dial" yaml:"aggressive_dial"`
+	StateWatching  bool `json:"state_watching" yaml:"state_watching"`
+	StatsTracking  bool `json:"stats_tracking" yaml:"stats_tracking"`
+}
+
+// NewGRpcClientPoolFromConfig create a pool configured by cfg, opts are
+// applied after cfg for settings it can't hold, e.g. hooks. An error is
+// returned if cfg is inconsistent or any option is invalid.
+func NewGRpcClientPoolFromConfig(addr string, cfg Config, dialF DialFunc, opts ...Option) (*GRpcClientPool, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	return NewGRpcClientPoolE(addr, dialF, cfg.MaxCount, cfg.IdleTimeout, append(cfg.options(), opts...)...)
+}
+
+// Validate check cfg is consistent
+func (cfg Config) Validate() error {
+	switch {
+	case cfg.MaxCount < 0:
+		return fmt.Errorf("Invalid config: MaxCount[%v] is negative", cfg.MaxCount)
+	case cfg.MinIdle < 0 || cfg.MaxIdle < 0:
+		return fmt.Errorf("Invalid config: MinIdle[%v] or MaxIdle[%v] is negative", cfg.MinIdle, cfg.MaxIdle)
+	case cfg.MaxCount > 0 && cfg.MinIdle > cfg.MaxCount:
+		return fmt.Errorf("Invalid config: MinIdle[%v] over MaxCount[%v]", cfg.MinIdle, cfg.MaxCount)
+	case cfg.MaxIdle > 0 && cfg.MinIdle > cfg.MaxIdle:
+		return fmt.Errorf("Invalid config: MinIdle[%v] over MaxIdle[%v]", cfg.MinIdle, cfg.MaxIdle)
+	case cfg.DialRateLimit < 0 || cfg.MaxConcurrentStreams < 0:
+		return fmt.Errorf("Invalid config: DialRateLimit[%v] or MaxConcurrentStreams[%v] is negative", cfg.DialRateLimit, cfg.MaxConcurrentStreams)
+	case cfg.MaxConcurrentDials < 0:
+		return fmt.Errorf("Invalid config: MaxConcurrentDials[%v] is negative", cfg.MaxConcurrentDials)
+	case cfg.MaxIdleReuses < 0 || cfg.MaxWaiters < 0 || cfg.StreamsPerConn < 0:
+		return fmt.Errorf("Invalid config: MaxIdleReuses[%v], MaxWaiters[%v] or StreamsPerConn[%v] is negative", cfg.MaxIdleReuses, cfg.MaxWaiters, cfg.StreamsPerConn)
+	case cfg.MaxOverflow < 0 || cfg.EventBuffer < 0 || cfg.EagerDial < 0:
+		return fmt.Errorf("Invalid config: MaxOverflow[%v], EventBuffer[%v] or EagerDial[%v] is negative", cfg.MaxOverflow, cfg.EventBuffer, cfg.EagerDial)
+	case cfg.RetryBudgetRatio < 0 || cfg.RetryBudgetMinPerSec < 0:
+		return fmt.Errorf("Invalid config: RetryBudgetRatio[%v] or RetryBudgetMinPerSec[%v] is negative", cfg.RetryBudgetRatio, cfg.RetryBudgetMinPerSec)
+	case cfg.HighWaterThreshold < 0 || cfg.HighWaterThreshold > 1:
+		return fmt.Errorf("Invalid config: HighWaterThreshold[%v] out of [0, 1]", cfg.HighWaterThreshold)
+	case cfg.IdleSelect < SelectFIFO || cfg.IdleSelect > SelectRandom:
+		return fmt.Errorf("Invalid config: IdleSelect[%v] unknown", cfg.IdleSelect)
+	case cfg.DialRateLimit > 0 && cfg.DialRateLimitPer <= 0:
+		return fmt.Errorf("Invalid config: DialRateLimit set without DialRateLimitPer")
+	}
+
+	for name, d := range map[string]time.Duration{
+		"IdleTimeout":       cfg.IdleTimeout,
+		"MaxLifetime":       cfg.MaxLifetime,
+		"ReapInterval":      cfg.ReapInterval,
+		"StaleScanInterval": cfg.StaleScanInterval,
+		"LeaseTimeout":      cfg.LeaseTimeout,
+		"DialTimeout":       cfg.DialTimeout,
+		"DialRateLimitPer":  cfg.DialRateLimitPer,
+		"DialLatencyBudget": cfg.DialLatencyBudget,
+		"MaxGetBlock":       cfg.MaxGetBlock,
+
+		"ServerMaxConnAge":    cfg.ServerMaxConnAge,
+		"ServerConnAgeMargin": cfg.ServerConnAgeMargin,
+
+		"KeepWarm":         cfg.KeepWarm,
+		"KeepaliveTime":    cfg.KeepaliveTime,
+		"KeepaliveTimeout": cfg.KeepaliveTimeout,
+		"NegativeCacheTTL": cfg.NegativeCacheTTL,
+		"ReconnectJitter":  cfg.ReconnectJitter,
+	} {
+		if d < 0 {
+			return fmt.Errorf("Invalid config: %v[%v] is negative", name, d)
+		}
+	}
+
+	return nil
+}
+
+// options convert cfg to options, MaxCount and IdleTimeout excluded
+func (cfg Config) options() []Option {
+	opts := []Option{
+		WithMinIdle(cfg.MinIdle),
+		WithMaxIdle(cfg.MaxIdle),
+		WithMaxLifetime(cfg.MaxLifetime),
+		WithReaper(cfg.ReapInterval),
+		WithStaleScanInterval(cfg.StaleScanInterval),
+		WithLeaseTimeout(cfg.LeaseTimeout),
+		WithMaxIdleReuses(cfg.MaxIdleReuses),
+		WithServerMaxConnAge(cfg.ServerMaxConnAge),
+		WithServerConnAgeMargin(cfg.ServerConnAgeMargin),
+		WithDialTimeout(cfg.DialTimeout),
+		WithDialRateLimit(cfg.DialRateLimit, cfg.DialRateLimitPer),
+		WithMaxDialLatencyBudget(cfg.DialLatencyBudget),
+		WithMaxGetBlock(cfg.MaxGetBlock),
+		WithMaxConcurrentStreams(cfg.MaxConcurrentStreams),
+		WithMaxConcurrentDials(cfg.MaxConcurrentDials),
+		WithLoadShedding(cfg.MaxWaiters),
+		WithStreamsPerConn(cfg.StreamsPerConn),
+		WithOverflow(cfg.MaxOverflow),
+		WithKeepWarm(cfg.KeepWarm),
+		WithIdleSelect(cfg.IdleSelect),
+		WithNegativeCacheTTL(cfg.NegativeCacheTTL),
+		WithEventBuffer(cfg.EventBuffer),
+		WithEagerDial(cfg.EagerDial),
+	}
+	if cfg.PauseBlocks {
+		opts = append(opts, WithPauseBlocks())
+	}
+	if cfg.ReviveIdle {
+		opts = append(opts, WithReviveIdle())
+	}
+	if cfg.CapturePeer {
+		opts = append(opts, WithCapturePeer())
+	}
+	if cfg.PreferFresh {
+		opts = append(opts, WithPreferFresh())
+	}
+	if cfg.IdleHeap {
+		opts = append(opts, WithIdleHeap())
+	}
+	if cfg.ReconnectJitter > 0 {
+		opts = append(opts, withReconnectJitter(cfg.ReconnectJitter))
+	}
+	if cfg.KeepaliveTime > 0 {
+		opts = append(opts, WithKeepaliveTime(cfg.KeepaliveTime))
+	}
+	if cfg.KeepaliveTimeout > 0 {
+		opts = append(opts, WithKeepaliveTimeout(cfg.KeepaliveTimeout))
+	}
+	if cfg.KeepalivePermitWithoutStream {
+		opts = append(opts, WithKeepalivePermitWithoutStream())
+	}
+	if cfg.RetryBudgetRatio > 0 || cfg.RetryBudgetMinPerSec > 0 {
+		opts = append(opts, WithRetryBudget(cfg.RetryBudgetRatio, cfg.RetryBudgetMinPerSec))
+	}
+	if cfg.HighWaterThreshold > 0 {
+		opts = append(opts, withHighWaterThreshold(cfg.HighWaterThreshold))
+	}
+	if cfg.AggressiveDial {
+		opts = append(opts, WithAggressiveDial())
+	}
+	if cfg.StateWatching {
+		opts = append(opts, WithStateWatching())
+	}
+	if cfg.StatsTracking {
+		opts = append(opts, WithStatsTracking())
+	}
+
+	return opts
+}
+
+// Config return the tunables of p, NewGRpcClientPoolFromConfig with it
+// create an equivalent pool
+func (p *GRpcClientPool) Config() Config {
+	p.Lock()
+	defer p.Unlock()
+
+	cfg := Config{
+		MaxCount:          p.maxCount,
+		IdleTimeout:       p.idleTimeout,
+		MinIdle:           p.minIdle,
+		MaxIdle:           p.maxIdle,
+		MaxLifetime:       p.maxLifetime,
+		ReapInterval:      p.reapInterval,
+		StaleScanInterval: p.scanInterval,
+		LeaseTimeout:      p.leaseTimeout,
+		MaxIdleReuses:     p.maxIdleReuses,
+		ServerMaxConnAge:  p.serverMaxConnAge,
+		DialTimeout:       p.dialTimeout,
+		DialLatencyBudget: p.latencyBudget,
+		MaxGetBlock:       p.maxGetBlock,
+		MaxWaiters:        p.maxWaiters,
+		StreamsPerConn:    p.streamsPerConn,
+		MaxOverflow:       p.maxOverflow,
+		PauseBlocks:       p.pauseBlocks,
+		ReviveIdle:        p.reviveIdle,
+		CapturePeer:       p.capturePeer,
+		KeepWarm:          p.keepWarm,
+		IdleSelect:        p.idleSelect,
+		PreferFresh:       p.preferFresh,
+		EagerDial:         p.eagerDial,
+		AggressiveDial:    p.aggressiveDial,
+		StateWatching:     p.watchState,
+		StatsTracking:     p.statsTracking,
+
+		ServerConnAgeMargin: p.connAgeMargin,
+		HighWaterThreshold:  p.highWater,
+		ReconnectJitter:     p.reconnectJitter,
+	}
+	if _, ok := p.pool.(*heapStore); ok {
+		cfg.IdleHeap = true
+	}
+	if p.keepalive != nil {
+		cfg.KeepaliveTime = p.keepalive.Time
+		cfg.KeepaliveTimeout = p.keepalive.Timeout
+		cfg.KeepalivePermitWithoutStream = p.keepalive.PermitWithoutStream
+	}
+	if p.retries != nil {
+		cfg.RetryBudgetRatio, cfg.RetryBudgetMinPerSec = p.retries.ratio, p.retries.minPerSec
+	}
+	if p.events != nil {
+		cfg.EventBuffer = len(p.events.buf)
+	}
+	if p.negCache != nil {
+		cfg.NegativeCacheTTL = p.negCache.ttl
+	}
+	if p.dialLimit != nil {
+		cfg.DialRateLimit, cfg.DialRateLimitPer = p.dialLimit.n, p.dialLimit.per
+	}
+	if p.streams != nil {
+		cfg.MaxConcurrentStreams = cap(p.streams)
+	}
+	if p.dialSlots != nil {
+		cfg.MaxConcurrentDials = cap(p.dialSlots)
+	}
+
+	return cfg
+}
+
+// ConfigJSON return Config of p as JSON for diagnostics, e.g. attached to bug
+// reports. Only tunables are included, no dial function or credentials.
+func (p *GRpcClientPool) ConfigJSON() ([]byte, error) {
+	return json.Marshal(p.Config())
+}
+
+// mapPoolConfig is the JSON of MapPool.ConfigJSON
+type mapPoolConfig struct {
+	MaxCount        int           `json:"max_count"`
+	IdleTimeout     time.Duration `json:"idle_timeout"`
+	MaxPools        int           `json:"max_pools"`
+	PingConcurrency int           `json:"ping_concurrency"`
+
+	// Config of pools by address, pools for credentials of an address share
+	// the same config
+	Pools map[string]Config `json:"pools"`
+	// Overrides by address, see SetDialFunc, SetPriority and MarkDraining
+	Overrides map[string]addrOverride `json:"overrides"`
+}
+
+type addrOverride struct {
+	DialFunc bool `json:"dial_func"`
+	Priority int  `json:"priority"`
+	Draining bool `json:"draining"`
+}
+
+// ConfigJSON return the tunables of mp, the Config of each pool and the
+// overrides of each address as JSON for diagnostics. Credential keys of
+// GetPoolFor are not included.
+func (mp *MapPool) ConfigJSON() ([]byte, error) {
+	mp.RLock()
+	cfg := mapPoolConfig{
+		MaxCount:        mp.maxCount,
+		IdleTimeout:     mp.idleTimeout,
+		MaxPools:        mp.maxPools,
+		PingConcurrency: mp.pingConcurrency,
+		Pools:           make(map[string]Config, len(mp.pools)),
+		Overrides:       make(map[string]addrOverride),
+	}
+	pools := make(map[string]*GRpcClientPool, len(mp.pools))
+	for key, p := range mp.pools {
+		pools[key.addr] = p
+	}
+	override := func(addr string, f func(o *addrOverride)) {
+		o := cfg.Overrides[addr]
+		f(&o)
+		cfg.Overrides[addr] = o
+	}
+	for addr := range mp.dialFs {
+		override(addr, func(o *addrOverride) { o.DialFunc = true })
+	}
+	for addr, pri := range mp.priorities {
+		override(addr, func(o *addrOverride) { o.Priority = pri })
+	}
+	for addr := range mp.draining {
+		override(addr, func(o *addrOverride) { o.Draining = true })
+	}
+	mp.RUnlock()
+
+	for addr, p := range pools {
+		cfg.Pools[addr] = p.Config()
+	}
+
+	return json.Marshal(cfg)
+}
@@ -0,0 +1,203 @@
+package grpc_pool
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestConfigRoundTrip(t *testing.T) {
+	cfg := Config{
+		MaxCount:             5,
+		IdleTimeout:          time.Minute,
+		MinIdle:              2,
+		MaxIdle:              4,
+		MaxLifetime:          time.Hour,
+		DialRateLimit:        3,
+		DialRateLimitPer:     time.Second,
+		MaxConcurrentStreams: 9,
+		ReviveIdle:           true,
+		DialTimeout:          time.Second,
+	}
+	p, err := NewGRpcClientPoolFromConfig("bufnet", cfg, newTestServer(t).DialFunc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	if got := p.Config(); got != cfg {
+		t.Fatalf("want %+v, got %+v", cfg, got)
+	}
+}
+
+func TestConfigInvalid(t *testing.T) {
+	for _, cfg := range []Config{
+		{MaxCount: -1},
+		{MaxCount: 1, MinIdle: 2},
+		{MinIdle: 3, MaxIdle: 2},
+		{DialRateLimit: 1},
+		{IdleTimeout: -time.Second},
+		{ReconnectJitter: -time.Second},
+	} {
+		if _, err := NewGRpcClientPoolFromConfig("bufnet", cfg, nil); err == nil {
+			t.Errorf("want %+v invalid", cfg)
+		}
+	}
+}
+
+func TestDialTimeout(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	slow := func(addr string) (*grpc.ClientConn, error) {
+		time.Sleep(100 * time.Millisecond)
+		return dial(addr)
+	}
+	p, err := NewGRpcClientPoolE("bufnet", slow, 1, time.Minute, WithDialTimeout(10*time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	if _, err := p.Get(); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the timed out dial uncounted, got %+v", s)
+	}
+}
+
+func TestConfigJSON(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithMinIdle(1), WithMaxLifetime(time.Hour), WithLoadShedding(3), WithServerMaxConnAge(time.Hour))
+
+	b, err := p.ConfigJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cfg Config
+	if err := json.Unmarshal(b, &cfg); err != nil || cfg != p.Config() || cfg.MaxWaiters != 3 || cfg.MinIdle != 1 {
+		t.Fatalf("want the config round tripped, got %+v, %v", cfg, err)
+	}
+	q, err := NewGRpcClientPoolFromConfig("bufnet", cfg, srv.DialFunc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer q.Release()
+	if got := q.Config(); got != cfg {
+		t.Fatalf("want %+v, got %+v", cfg, got)
+	}
+}
+
+func TestConfigJSONFull(t *testing.T) {
+	srv := newTestServer(t)
+	cfg := Config{
+		MaxCount:                     5,
+		IdleTimeout:                  time.Minute,
+		MinIdle:                      1,
+		MaxIdle:                      4,
+		MaxLifetime:                  time.Hour,
+		ReapInterval:                 time.Minute,
+		StaleScanInterval:            time.Second,
+		LeaseTimeout:                 time.Hour,
+		MaxIdleReuses:                100,
+		ServerMaxConnAge:             2 * time.Hour,
+		ServerConnAgeMargin:          time.Minute,
+		DialTimeout:                  time.Second,
+		DialRateLimit:                10,
+		DialRateLimitPer:             time.Second,
+		DialLatencyBudget:            time.Second,
+		MaxGetBlock:                  time.Second,
+		MaxConcurrentStreams:         9,
+		MaxConcurrentDials:           2,
+		MaxWaiters:                   3,
+		StreamsPerConn:               8,
+		MaxOverflow:                  2,
+		PauseBlocks:                  true,
+		ReviveIdle:                   true,
+		CapturePeer:                  true,
+		KeepWarm:                     time.Minute,
+		IdleSelect:                   SelectLIFO,
+		PreferFresh:                  true,
+		IdleHeap:                     true,
+		ReconnectJitter:              time.Second,
+		KeepaliveTime:                time.Minute,
+		KeepaliveTimeout:             time.Second,
+		KeepalivePermitWithoutStream: true,
+		RetryBudgetRatio:             0.2,
+		RetryBudgetMinPerSec:         3,
+		NegativeCacheTTL:             time.Second,
+		EventBuffer:                  16,
+		EagerDial:                    1,
+		HighWaterThreshold:           0.8,
+		AggressiveDial:               true,
+		StateWatching:                true,
+		StatsTracking:                true,
+	}
+	p, err := NewGRpcClientPoolFromConfig("bufnet", cfg, srv.DialFunc(), WithDialOptionsFunc(srv.DialOptionsFunc()),
+		WithHealthCheck(func(context.Context, *IdleClient) error { return nil }), WithHighWaterCallback(0.8, func(int, int) {}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	b, err := p.ConfigJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got Config
+	if err := json.Unmarshal(b, &got); err != nil || got != cfg {
+		t.Fatalf("want %+v, got %+v, %v", cfg, got, err)
+	}
+}
+
+func TestMapPoolConfigJSON(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 3, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	mp.GetPoolFor("bufnet", "secret-token")
+	mp.SetPriority("other", 2)
+	mp.MarkDraining("bufnet")
+
+	b, err := mp.ConfigJSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(b), "secret") {
+		t.Fatalf("want credentials omitted, got %s", b)
+	}
+	var cfg struct {
+		Pools     map[string]Config
+		Overrides map[string]struct {
+			Priority int
+			Draining bool
+		}
+	}
+	if err := json.Unmarshal(b, &cfg); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := cfg.Pools["bufnet"]; !ok || cfg.Overrides["other"].Priority != 2 || !cfg.Overrides["bufnet"].Draining {
+		t.Fatalf("want pools and overrides, got %s", b)
+	}
+}
+
+func TestConfigNoIdleTimeout(t *testing.T) {
+	p, err := NewGRpcClientPoolFromConfig("bufnet", Config{MaxCount: 5}, newTestServer(t).DialFunc())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	c, _ := p.Get()
+	p.Put(c)
+	time.Sleep(10 * time.Millisecond)
+	if d, _ := p.Get(); d != c {
+		t.Fatal("want the client reused without idle timeout")
+	}
+	if s := p.Stats(); s.Timeouts != 0 {
+		t.Fatalf("want no timeout, got %+v", s)
+	}
+}
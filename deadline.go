@@ -0,0 +1,68 @@
+package grpc_pool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithDefaultDeadline give rpcs on pooled connections a deadline of d unless
+// the context has one already. It installs interceptors by dial options, so
+// needs a DialOptionsFunc as WithDialOptions.
+func WithDefaultDeadline(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if d <= 0 {
+			p.invalid(fmt.Errorf("Default deadline[%v] not positive", d))
+			return
+		}
+		p.dialOpts = append(p.dialOpts,
+			grpc.WithChainUnaryInterceptor(deadlineUnaryInterceptor(d)),
+			grpc.WithChainStreamInterceptor(deadlineStreamInterceptor(d)),
+		)
+	}
+}
+
+// withDefaultDeadline return ctx with deadline d if it has none
+func withDefaultDeadline(ctx context.Context, d time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d)
+}
+
+func deadlineUnaryInterceptor(d time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, cancel := withDefaultDeadline(ctx, d)
+		defer cancel()
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+func deadlineStreamInterceptor(d time.Duration) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, cancel := withDefaultDeadline(ctx, d)
+		s, err := streamer(ctx, desc, cc, method, opts...)
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		return &deadlineStream{ClientStream: s, cancel: cancel}, nil
+	}
+}
+
+// deadlineStream release the timer of its deadline once the stream ended,
+// which is when RecvMsg return an error, io.EOF included
+type deadlineStream struct {
+	grpc.ClientStream
+	cancel context.CancelFunc
+}
+
+func (s *deadlineStream) RecvMsg(m interface{}) error {
+	err := s.ClientStream.RecvMsg(m)
+	if err != nil {
+		s.cancel()
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package grpc_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// deadlineHealth report whether rpcs have a deadline, Check blocks until
+// the deadline
+type deadlineHealth struct {
+	healthpb.UnimplementedHealthServer
+	hasDeadline chan bool
+}
+
+func (h *deadlineHealth) Check(ctx context.Context, _ *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	_, ok := ctx.Deadline()
+	h.hasDeadline <- ok
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (h *deadlineHealth) Watch(_ *healthpb.HealthCheckRequest, ws healthpb.Health_WatchServer) error {
+	_, ok := ws.Context().Deadline()
+	h.hasDeadline <- ok
+	return ws.Send(&healthpb.HealthCheckResponse{})
+}
+
+func TestDefaultDeadline(t *testing.T) {
+	h := &deadlineHealth{hasDeadline: make(chan bool, 2)}
+	srv := testutil.NewServer(func(s *grpc.Server) { healthpb.RegisterHealthServer(s, h) })
+	t.Cleanup(srv.Stop)
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithDefaultDeadline(100*time.Millisecond))
+
+	c, _ := p.Get()
+	client := healthpb.NewHealthClient(c.GetConn())
+
+	start := time.Now()
+	_, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.DeadlineExceeded || !<-h.hasDeadline {
+		t.Fatalf("want the default deadline applied, got %v", err)
+	}
+	if d := time.Since(start); d > 2*time.Second {
+		t.Fatalf("want the rpc cut at the default deadline, took %v", d)
+	}
+
+	ws, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ws.Recv()
+	if !<-h.hasDeadline {
+		t.Fatal("want the default deadline applied to streams")
+	}
+}
+
+func TestDefaultDeadlineKeepCallerDeadline(t *testing.T) {
+	h := &deadlineHealth{hasDeadline: make(chan bool, 1)}
+	srv := testutil.NewServer(func(s *grpc.Server) { healthpb.RegisterHealthServer(s, h) })
+	t.Cleanup(srv.Stop)
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithDefaultDeadline(time.Minute))
+
+	c, _ := p.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	healthpb.NewHealthClient(c.GetConn()).Check(ctx, &healthpb.HealthCheckRequest{})
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("want the caller deadline kept, took %v", d)
+	}
+}
+
+func TestDefaultDeadlineInvalid(t *testing.T) {
+	if _, err := NewGRpcClientPoolE("bufnet", nil, 1, 0, WithDefaultDeadline(0)); err == nil {
+		t.Fatal("want a zero default deadline invalid")
+	}
+}
@@ -0,0 +1,32 @@
+package grpc_pool
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// WithContextDialer make connections dial the network by d, e.g. one from
+// UserTimeoutDialer to set socket options. Its errors are watched for file
+// descriptors exhausted. It's a dial option, so needs a DialOptionsFunc as
+// WithDialOptions.
+func WithContextDialer(d func(ctx context.Context, addr string) (net.Conn, error)) Option {
+	return func(p *GRpcClientPool) {
+		if d != nil {
+			p.dialOpts = append(p.dialOpts, grpc.WithContextDialer(p.watchFD(d)))
+		}
+	}
+}
+
+// UserTimeoutDialer return a dialer for WithContextDialer setting
+// TCP_USER_TIMEOUT of connections to d, so a dead peer is detected when sent
+// data isn't acked within d. The option is only supported on Linux, it's
+// ignored elsewhere.
+func UserTimeoutDialer(d time.Duration) func(ctx context.Context, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Control: userTimeoutControl(d)}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		return dialer.DialContext(ctx, "tcp", addr)
+	}
+}
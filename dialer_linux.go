@@ -0,0 +1,22 @@
+package grpc_pool
+
+import (
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// userTimeoutControl set TCP_USER_TIMEOUT of sockets to d
+func userTimeoutControl(d time.Duration) func(network, address string, c syscall.RawConn) error {
+	return func(network, address string, c syscall.RawConn) error {
+		var err error
+		ctrlErr := c.Control(func(fd uintptr) {
+			err = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_USER_TIMEOUT, int(d/time.Millisecond))
+		})
+		if ctrlErr != nil {
+			return ctrlErr
+		}
+		return err
+	}
+}
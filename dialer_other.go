@@ -0,0 +1,13 @@
+//go:build !linux
+
+package grpc_pool
+
+import (
+	"syscall"
+	"time"
+)
+
+// userTimeoutControl do nothing, TCP_USER_TIMEOUT is Linux only
+func userTimeoutControl(time.Duration) func(network, address string, c syscall.RawConn) error {
+	return nil
+}
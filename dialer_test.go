@@ -0,0 +1,27 @@
+package grpc_pool
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestContextDialer(t *testing.T) {
+	srv := newTestServer(t)
+	var dials atomic.Int32
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		dials.Add(1)
+		return srv.DialContext(ctx, addr)
+	}
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithContextDialer(dialer))
+
+	c, _ := p.Get()
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	if n := dials.Load(); n != 1 {
+		t.Fatalf("want the custom dialer invoked once, got %v", n)
+	}
+}
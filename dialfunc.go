@@ -0,0 +1,75 @@
+package grpc_pool
+
+import (
+	"google.golang.org/grpc"
+)
+
+// DialFuncWith return a DialFunc dialing by DefaultDialOptionsFunc with opts,
+// e.g. to install interceptors:
+//
+//	NewGRpcClientPool(addr, DialFuncWith(ChainUnaryInterceptors(a, b)), maxCount, idleTimeout)
+func DialFuncWith(opts ...grpc.DialOption) DialFunc {
+	return func(addr string) (*grpc.ClientConn, error) {
+		return DefaultDialOptionsFunc(addr, opts...)
+	}
+}
+
+// SetDialFunc change the dial function used for new connections, e.g. to
+// dial with rotated credentials. Connections already dialed are kept until
+// retired as usual. It replaces the function set by WithDialOptionsFunc too,
+// and as a DialFunc given to NewGRpcClientPool f doesn't receive the dial
+// options of the pool, so ERROR_DIAL_OPTIONS_IGNORED is returned and nothing
+// changed if the pool has any, use SetDialOptionsFunc then. A nil f means
+// DefaultDialOptionsFunc.
+func (p *GRpcClientPool) SetDialFunc(f DialFunc) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if f == nil {
+		p.dialF, p.dialOptsF = nil, DefaultDialOptionsFunc
+		return nil
+	}
+	if len(p.dialOpts) > 0 || p.statsTracking || p.capturePeer {
+		return ERROR_DIAL_OPTIONS_IGNORED
+	}
+	p.dialF, p.dialOptsF = f, nil
+
+	return nil
+}
+
+// SetDialOptionsFunc is like SetDialFunc, but f receive the dial options of
+// the pool as one set by WithDialOptionsFunc, so keepalive, stats handlers
+// etc. keep applying to new connections. A nil f means
+// DefaultDialOptionsFunc.
+func (p *GRpcClientPool) SetDialOptionsFunc(f DialOptionsFunc) {
+	p.Lock()
+	defer p.Unlock()
+
+	if f == nil {
+		f = DefaultDialOptionsFunc
+	}
+	p.dialF, p.dialOptsF = nil, f
+}
+
+// ChainUnaryInterceptors return a dial option installing interceptors for
+// unary rpcs, the first is the outermost. nil ones are skipped.
+func ChainUnaryInterceptors(interceptors ...grpc.UnaryClientInterceptor) grpc.DialOption {
+	chain := make([]grpc.UnaryClientInterceptor, 0, len(interceptors))
+	for _, i := range interceptors {
+		if i != nil {
+			chain = append(chain, i)
+		}
+	}
+	return grpc.WithChainUnaryInterceptor(chain...)
+}
+
+// ChainStreamInterceptors is like ChainUnaryInterceptors, but for stream rpcs
+func ChainStreamInterceptors(interceptors ...grpc.StreamClientInterceptor) grpc.DialOption {
+	chain := make([]grpc.StreamClientInterceptor, 0, len(interceptors))
+	for _, i := range interceptors {
+		if i != nil {
+			chain = append(chain, i)
+		}
+	}
+	return grpc.WithChainStreamInterceptor(chain...)
+}
@@ -0,0 +1,147 @@
+package grpc_pool
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// tracing return interceptors appending name to order
+func tracing(name string, order *[]string) (grpc.UnaryClientInterceptor, grpc.StreamClientInterceptor) {
+	unary := func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		*order = append(*order, name)
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+	stream := func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		*order = append(*order, name)
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+	return unary, stream
+}
+
+func TestDialFuncWithInterceptors(t *testing.T) {
+	srv := newTestServer(t)
+	var order []string
+	ua, sa := tracing("a", &order)
+	ub, sb := tracing("b", &order)
+	dialF := DialFuncWith(grpc.WithContextDialer(srv.DialContext),
+		ChainUnaryInterceptors(ua, nil, ub), ChainStreamInterceptors(sa, sb, nil))
+	p, err := NewGRpcClientPoolE("passthrough:///bufnet", dialF, 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	c, _ := p.Get()
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"a", "b"}) {
+		t.Fatalf("want both unary interceptors in order, got %v", order)
+	}
+
+	order = nil
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if _, err := healthpb.NewHealthClient(c.GetConn()).Watch(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(order, []string{"a", "b"}) {
+		t.Fatalf("want both stream interceptors in order, got %v", order)
+	}
+}
+
+func TestSetDialFunc(t *testing.T) {
+	srv := newTestServer(t)
+	oldF, oldDials := countingDial(srv)
+	newF, newDials := countingDial(srv)
+	p, _ := NewGRpcClientPoolE("bufnet", oldF, 5, time.Minute)
+	defer p.Release()
+
+	c1, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.SetDialFunc(newF); err != nil {
+		t.Fatal(err)
+	}
+	c2, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if oldDials.Load() != 1 || newDials.Load() != 1 {
+		t.Fatalf("want the new dial after swapped, got %v old and %v new", oldDials.Load(), newDials.Load())
+	}
+
+	// the old client is kept
+	p.Put(c1)
+	p.Put(c2)
+	if s := p.Stats(); s.Idle != 2 {
+		t.Fatalf("want both clients pooled, got %+v", s)
+	}
+}
+
+func TestSetDialOptionsFunc(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithStatsTracking())
+
+	// the stats handler would be lost
+	if err := p.SetDialFunc(srv.DialFunc()); err != ERROR_DIAL_OPTIONS_IGNORED {
+		t.Fatalf("want ERROR_DIAL_OPTIONS_IGNORED, got %v", err)
+	}
+
+	var dials atomic.Int32
+	dialOptsF := srv.DialOptionsFunc()
+	p.SetDialOptionsFunc(func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		dials.Add(1)
+		return dialOptsF(addr, opts...)
+	})
+	ctx := context.Background()
+	err := p.Do(ctx, func(conn *grpc.ClientConn) error {
+		return conn.Invoke(ctx, "/unknown.Service/Method", nil, nil)
+	})
+	if err == nil || dials.Load() != 1 {
+		t.Fatalf("want the rpc failed on a conn of the new func, got %v after %v dials", err, dials.Load())
+	}
+	waitUntil(t, func() bool { return p.Stats().RPCFailure == 1 })
+}
+
+func ExampleDialFuncWith() {
+	srv := testutil.NewServer(func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+	defer srv.Stop()
+
+	logging := func(name string) grpc.UnaryClientInterceptor {
+		return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+			fmt.Println(name, method)
+			return invoker(ctx, method, req, reply, cc, opts...)
+		}
+	}
+	dialF := DialFuncWith(
+		grpc.WithContextDialer(srv.DialContext),
+		ChainUnaryInterceptors(logging("outer"), logging("inner")),
+	)
+	pool := NewGRpcClientPool("passthrough:///bufnet", dialF, 5, time.Minute)
+	defer pool.Release()
+
+	c, err := pool.Get()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer pool.Put(c)
+	healthpb.NewHealthClient(c.GetConn()).Check(context.Background(), &healthpb.HealthCheckRequest{})
+
+	// Output:
+	// outer /grpc.health.v1.Health/Check
+	// inner /grpc.health.v1.Health/Check
+}
@@ -0,0 +1,88 @@
+package grpc_pool
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultRetirePolicy retire the connection when the rpc failed at transport
+// level, e.g. the server is unreachable or the call timed out. Any other error
+// is returned by the server, so the connection is kept.
+func DefaultRetirePolicy(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded:
+		return true
+	}
+	return false
+}
+
+// WithMaxConcurrentStreams limit in-flight calls of Do to n, whatever the
+// number of connections, since HTTP/2 multiplexes many streams on one
+// connection. Do beyond it block until a call done or ctx done.
+func WithMaxConcurrentStreams(n int) Option {
+	return func(p *GRpcClientPool) {
+		if n > 0 {
+			p.streams = make(chan struct{}, n)
+		}
+	}
+}
+
+// Do take a connection from pool and call fn with it. The connection is given
+// back after fn returned, or retired if the retire policy consider the error
+// returned by fn means the connection is bad, fn is retried then if a retry
+// budget set, see WithRetryBudget.
+func (p *GRpcClientPool) Do(ctx context.Context, fn func(conn *grpc.ClientConn) error) error {
+	if p.streams != nil {
+		select {
+		case p.streams <- struct{}{}:
+			defer func() { <-p.streams }()
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	if p.retries != nil {
+		p.retries.deposit()
+	}
+
+	for retries := 0; ; retries++ {
+		c, err := p.GetContext(ctx)
+		if err != nil {
+			return err
+		}
+
+		err = fn(c.GetConn())
+		if !p.statsTracking {
+			p.rpcResults.record(err)
+		}
+		if err == nil || !p.retirePolicy(err) {
+			p.Put(c)
+			return err
+		}
+		p.DelErrorClient(c)
+
+		if p.retries == nil || retries >= doMaxRetries || ctx.Err() != nil || !p.retries.withdraw(time.Now()) {
+			return err
+		}
+	}
+}
+
+// WithClient call fn with a stub built by newStub on a connection from p, see
+// Do for how the connection is given back or retired, e.g.
+//
+//	err := WithClient(ctx, p, pb.NewGreeterClient, func(c pb.GreeterClient) error {
+//		_, err := c.SayHello(ctx, req)
+//		return err
+//	})
+//
+// Stubs generated to take a grpc.ClientConnInterface need wrapping in a func
+// taking *grpc.ClientConn.
+func WithClient[T any](ctx context.Context, p *GRpcClientPool, newStub func(*grpc.ClientConn) T, fn func(T) error) error {
+	return p.Do(ctx, func(conn *grpc.ClientConn) error {
+		return fn(newStub(conn))
+	})
+}
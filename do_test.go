@@ -0,0 +1,150 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestDefaultRetirePolicy(t *testing.T) {
+	for _, tt := range []struct {
+		err    error
+		retire bool
+	}{
+		{status.Error(codes.Unavailable, ""), true},
+		{status.Error(codes.DeadlineExceeded, ""), true},
+		{status.Error(codes.NotFound, ""), false},
+		{status.Error(codes.Internal, ""), false},
+		{status.Error(codes.PermissionDenied, ""), false},
+		{errors.New("not a status"), false},
+	} {
+		if got := DefaultRetirePolicy(tt.err); got != tt.retire {
+			t.Errorf("DefaultRetirePolicy(%v) = %v, want %v", tt.err, got, tt.retire)
+		}
+	}
+}
+
+func TestWithClient(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+	ctx := context.Background()
+	newStub := func(conn *grpc.ClientConn) healthpb.HealthClient { return healthpb.NewHealthClient(conn) }
+
+	err := WithClient(ctx, p, newStub, func(c healthpb.HealthClient) error {
+		resp, err := c.Check(ctx, &healthpb.HealthCheckRequest{})
+		if err == nil && resp.Status != healthpb.HealthCheckResponse_SERVING {
+			err = errors.New(resp.Status.String())
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Count != 1 || s.Idle != 1 {
+		t.Fatalf("want the client given back, got %+v", s)
+	}
+
+	// retired by the retire policy as Do
+	err = WithClient(ctx, p, newStub, func(healthpb.HealthClient) error {
+		return status.Error(codes.Unavailable, "")
+	})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("want the error of fn, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the client retired, got %+v", s)
+	}
+}
+
+func TestDoRetireByPolicy(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	p.Do(context.Background(), func(*grpc.ClientConn) error { return status.Error(codes.Unavailable, "") })
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the client retired, got %+v", s)
+	}
+
+	p.Do(context.Background(), func(*grpc.ClientConn) error { return status.Error(codes.NotFound, "") })
+	if s := p.Stats(); s.Count != 1 || s.Idle != 1 {
+		t.Fatalf("want the client given back, got %+v", s)
+	}
+}
+
+func TestDoCustomRetirePolicy(t *testing.T) {
+	retire := errors.New("retire")
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithRetirePolicy(func(err error) bool {
+		return err == retire
+	}))
+
+	p.Do(context.Background(), func(*grpc.ClientConn) error { return status.Error(codes.Unavailable, "") })
+	if s := p.Stats(); s.Idle != 1 {
+		t.Fatalf("want Unavailable kept by the custom policy, got %+v", s)
+	}
+
+	if err := p.Do(context.Background(), func(*grpc.ClientConn) error { return retire }); err != retire {
+		t.Fatalf("want the rpc error, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the client retired, got %+v", s)
+	}
+}
+
+func TestDoMaxConcurrentStreams(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxConcurrentStreams(3))
+
+	var cur, max atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.Do(context.Background(), func(*grpc.ClientConn) error {
+				n := cur.Add(1)
+				for m := max.Load(); n > m && !max.CompareAndSwap(m, n); m = max.Load() {
+				}
+				time.Sleep(10 * time.Millisecond)
+				cur.Add(-1)
+				return nil
+			})
+		}()
+	}
+	wg.Wait()
+
+	if got := max.Load(); got != 3 {
+		t.Fatalf("want 3 calls in flight at most, got %v", got)
+	}
+}
+
+func TestDoMaxConcurrentStreamsSaturated(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxConcurrentStreams(1))
+
+	hold, release := make(chan struct{}), make(chan struct{})
+	go p.Do(context.Background(), func(*grpc.ClientConn) error {
+		close(hold)
+		<-release
+		return nil
+	})
+	<-hold
+	defer close(release)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	called := false
+	err := p.Do(ctx, func(*grpc.ClientConn) error {
+		called = true
+		return nil
+	})
+	if err != context.DeadlineExceeded || called {
+		t.Fatalf("want DeadlineExceeded without calling fn, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 1 {
+		t.Fatalf("want connections available beyond the limit, got %+v", s)
+	}
+}
@@ -0,0 +1,64 @@
+package grpc_pool
+
+// MarkDraining stop the pools of addr dialing and handing out connections, e.g.
+// on notice the backend is being drained. Idle connections are closed and
+// checked out ones when given back, Get return ERROR_POOL_DRAINING until
+// MarkActive. A pool created later for addr starts draining.
+func (mp *MapPool) MarkDraining(addr string) {
+	mp.Lock()
+	defer mp.Unlock()
+
+	mp.draining[addr] = struct{}{}
+	// under the lock, or racing calls may leave pools out of sync with it
+	for _, p := range mp.poolsOf(addr) {
+		p.setDraining(true)
+	}
+}
+
+// MarkActive undo MarkDraining of addr
+func (mp *MapPool) MarkActive(addr string) {
+	mp.Lock()
+	defer mp.Unlock()
+
+	delete(mp.draining, addr)
+	for _, p := range mp.poolsOf(addr) {
+		p.setDraining(false)
+	}
+}
+
+// poolsOf return pools of addr for all credentials, mp MUST be locked or
+// read locked
+func (mp *MapPool) poolsOf(addr string) []*GRpcClientPool {
+	var pools []*GRpcClientPool
+	for key, p := range mp.pools {
+		if key.addr == addr {
+			pools = append(pools, p)
+		}
+	}
+	return pools
+}
+
+// IsDraining report whether addr is marked draining
+func (mp *MapPool) IsDraining(addr string) bool {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	_, ok := mp.draining[addr]
+	return ok
+}
+
+// setDraining start or stop draining p, idle conns are closed on start
+func (p *GRpcClientPool) setDraining(on bool) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.draining = on
+	if !on {
+		return
+	}
+
+	for _, c := range p.pool.clear() {
+		p.retire(c)
+	}
+	p.idlePeak = 0
+}
@@ -0,0 +1,79 @@
+package grpc_pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// isClosed report whether c closed by p
+func isClosed(p *GRpcClientPool, c *IdleClient) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return c.closed
+}
+
+func TestMarkDraining(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	pa := mp.GetPool("a")
+	c1, _ := pa.Get()
+	c2, _ := pa.Get()
+	pa.Put(c1)
+	mp.MarkDraining("a")
+	// b has no pool yet, marked for the pool created later
+	mp.MarkDraining("b")
+
+	if !isClosed(pa, c1) || pa.Stats().Count != 1 {
+		t.Fatalf("want the idle client closed, got %+v", pa.Stats())
+	}
+	if _, err := pa.Get(); err != ERROR_POOL_DRAINING {
+		t.Fatalf("want ERROR_POOL_DRAINING, got %v", err)
+	}
+	pa.Put(c2)
+	if !isClosed(pa, c2) || pa.Stats().Count != 0 {
+		t.Fatalf("want the client given back retired, got %+v", pa.Stats())
+	}
+	if _, err := mp.GetPool("b").Get(); err != ERROR_POOL_DRAINING || !mp.IsDraining("b") {
+		t.Fatalf("want ERROR_POOL_DRAINING, got %v", err)
+	}
+
+	mp.MarkActive("a")
+	c3, err := pa.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pa.Put(c3)
+	if s := pa.Stats(); s.Idle != 1 {
+		t.Fatalf("want the client pooled again, got %+v", s)
+	}
+}
+
+func TestMarkDrainingConcurrent(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	p := mp.GetPool("a")
+	for i := 0; i < 200; i++ {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			mp.MarkDraining("a")
+		}()
+		go func() {
+			defer wg.Done()
+			mp.MarkActive("a")
+		}()
+		wg.Wait()
+
+		p.Lock()
+		draining := p.draining
+		p.Unlock()
+		if draining != mp.IsDraining("a") {
+			t.Fatalf("want the pool draining as the registry, round %v", i)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package grpc_pool
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DialError is returned by Get when dialing a new connection failed, e.g. the
+// backend is unreachable or the address can't be resolved
+type DialError struct {
+	Addr string
+	Err  error
+}
+
+func (e *DialError) Error() string {
+	return fmt.Sprintf("Dial [%v] failed: %v", e.Addr, e.Err)
+}
+
+func (e *DialError) Unwrap() error {
+	return e.Err
+}
+
+// IsCapacityError report whether err means the pool can't give out a
+// connection now without exceeding its limits, callers may shed load
+func IsCapacityError(err error) bool {
+	return errors.Is(err, ERROR_MAX_CLIENT_COUNT) ||
+		errors.Is(err, ERROR_DIAL_RATE_LIMITED) ||
+		errors.Is(err, ERROR_BUDGET_EXCEEDED) ||
+		errors.Is(err, ERROR_OVERLOADED) ||
+		errors.Is(err, ERROR_FD_EXHAUSTED)
+}
+
+// IsDialError report whether err means dialing the backend failed, callers
+// may break the circuit
+func IsDialError(err error) bool {
+	var de *DialError
+	return errors.As(err, &de)
+}
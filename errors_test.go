@@ -0,0 +1,58 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestCapacityError(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute)
+
+	p.Get()
+	_, err := p.Get()
+	if !IsCapacityError(err) || IsDialError(err) {
+		t.Fatalf("want a capacity error, got %v", err)
+	}
+}
+
+func TestCapacityErrorShedding(t *testing.T) {
+	for _, err := range []error{ERROR_OVERLOADED, ERROR_FD_EXHAUSTED, fmt.Errorf("dial: %w", ERROR_FD_EXHAUSTED)} {
+		if !IsCapacityError(err) {
+			t.Errorf("want %v a capacity error", err)
+		}
+	}
+}
+
+func TestDialError(t *testing.T) {
+	down := errors.New("down")
+	p := NewGRpcClientPool("bufnet", func(string) (*grpc.ClientConn, error) { return nil, down }, 1, time.Minute)
+	defer p.Release()
+
+	_, err := p.Get()
+	if IsCapacityError(err) || !IsDialError(err) || !errors.Is(err, down) {
+		t.Fatalf("want a dial error wrapping down, got %v", err)
+	}
+	var de *DialError
+	if !errors.As(err, &de) || de.Addr != "bufnet" {
+		t.Fatalf("want the address in the dial error, got %v", err)
+	}
+}
+
+func TestDialErrorTimeout(t *testing.T) {
+	slow := func(string) (*grpc.ClientConn, error) {
+		time.Sleep(100 * time.Millisecond)
+		return nil, errors.New("down")
+	}
+	p := NewGRpcClientPool("bufnet", slow, 1, time.Minute, WithDialTimeout(10*time.Millisecond))
+	defer p.Release()
+
+	_, err := p.GetContext(context.Background())
+	if !IsDialError(err) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("want a dial error wrapping DeadlineExceeded, got %v", err)
+	}
+}
@@ -0,0 +1,85 @@
+package grpc_pool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// EventKind is the kind of a pool lifecycle event, see WithEventBuffer
+type EventKind string
+
+const (
+	EventDial      EventKind = "dial"
+	EventDialError EventKind = "dial_error"
+	EventReap      EventKind = "reap"
+	EventRetire    EventKind = "retire"
+	EventPause     EventKind = "pause"
+	EventResume    EventKind = "resume"
+	EventResize    EventKind = "resize"
+)
+
+// Event is a pool lifecycle event recorded by WithEventBuffer
+type Event struct {
+	Time   time.Time
+	Kind   EventKind
+	Detail string
+}
+
+// WithEventBuffer keep the last n lifecycle events in memory, e.g. for
+// forensics after an incident without a logger wired, see Events
+func WithEventBuffer(n int) Option {
+	return func(p *GRpcClientPool) {
+		if n > 0 {
+			p.events = &eventLog{buf: make([]Event, n)}
+		}
+	}
+}
+
+// Events return the events kept by WithEventBuffer, oldest first, nil if not
+// enabled
+func (p *GRpcClientPool) Events() []Event {
+	if p.events == nil {
+		return nil
+	}
+	return p.events.list()
+}
+
+// event record an event if WithEventBuffer is set
+func (p *GRpcClientPool) event(kind EventKind, format string, v ...interface{}) {
+	if p.events != nil {
+		p.events.add(Event{Time: time.Now(), Kind: kind, Detail: fmt.Sprintf(format, v...)})
+	}
+}
+
+// eventLog is a ring buffer of events
+type eventLog struct {
+	sync.Mutex
+	buf []Event
+	// Next slot to write, and events kept
+	next int
+	n    int
+}
+
+func (l *eventLog) add(e Event) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.buf[l.next] = e
+	l.next = (l.next + 1) % len(l.buf)
+	if l.n < len(l.buf) {
+		l.n++
+	}
+}
+
+func (l *eventLog) list() []Event {
+	l.Lock()
+	defer l.Unlock()
+
+	events := make([]Event, 0, l.n)
+	start := (l.next - l.n + len(l.buf)) % len(l.buf)
+	for i := 0; i < l.n; i++ {
+		events = append(events, l.buf[(start+i)%len(l.buf)])
+	}
+	return events
+}
@@ -0,0 +1,33 @@
+package grpc_pool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestEvents(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, 20*time.Millisecond, WithEventBuffer(4))
+
+	c, _ := p.Get()
+	p.Put(c)
+	p.Pause()
+	p.Resume()
+	time.Sleep(30 * time.Millisecond)
+	p.Get()
+	p.BoostMaxCount(1, time.Hour)
+
+	// the last 4 in order, the dial, pause and resume rotated out
+	var kinds []EventKind
+	for _, e := range p.Events() {
+		kinds = append(kinds, e.Kind)
+	}
+	if want := []EventKind{EventRetire, EventReap, EventDial, EventResize}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("want %v, got %v", want, kinds)
+	}
+
+	if events := newTestPool(t, srv, 5, time.Minute).Events(); events != nil {
+		t.Fatalf("want no events without the buffer, got %v", events)
+	}
+}
@@ -2,15 +2,32 @@ package main
 
 import (
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
 	"github.com/SongLiangChen/grpc_pool"
 	"golang.org/x/net/context"
+	"google.golang.org/grpc"
 )
 
+// logging log every rpc
+func logging(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	log.Printf("call %v", method)
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// timing log how long every rpc took
+func timing(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	log.Printf("%v took %v", method, time.Since(start))
+	return err
+}
+
 func main() {
-	var pool = grpc_pool.NewGRpcClientPool("127.0.0.1:8080", nil, 5, time.Second*10)
+	dialF := grpc_pool.DialFuncWith(grpc_pool.ChainUnaryInterceptors(logging, timing))
+	var pool = grpc_pool.NewGRpcClientPool("127.0.0.1:8080", dialF, 5, time.Second*10)
 	defer pool.Release()
 
 	wg := sync.WaitGroup{}
@@ -37,4 +54,16 @@ func main() {
 	}
 
 	wg.Wait()
+
+	// the same call, giving back or retiring the connection by WithClient
+	err := grpc_pool.WithClient(context.Background(), pool, NewGreeterClient, func(c GreeterClient) error {
+		r, err := c.SayHello(context.Background(), &HelloRequest{Name: "SongLiangChen"})
+		if err == nil {
+			fmt.Println(r.Message)
+		}
+		return err
+	})
+	if err != nil {
+		log.Println(err)
+	}
 }
@@ -0,0 +1,79 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+)
+
+// Interval of probe dials while file descriptors exhausted, see fdBlocked
+const fdProbeBackoff = time.Second
+
+// fdExhaustedError report whether err means the process or system ran out of
+// file descriptors
+func fdExhaustedError(err error) bool {
+	return errors.Is(err, syscall.EMFILE) || errors.Is(err, syscall.ENFILE)
+}
+
+// noteDialError switch the pool to degraded mode if err means file
+// descriptors exhausted: no dial until a connection closed frees one or a
+// probe dial succeeds, Gets are served by existing connections only. p MUST
+// be locked.
+//
+// Only errors seen by the pool are detected: ones returned by a DialFunc
+// connecting synchronously, and ones of the dialer set by WithContextDialer,
+// see watchFD. Clients created by grpc.NewClient connect lazily in gRPC's own
+// dialer, whose errors never reach the pool.
+func (p *GRpcClientPool) noteDialError(err error) {
+	if !fdExhaustedError(err) {
+		return
+	}
+	p.fdProbeAt = time.Now().Add(fdProbeBackoff)
+	if !p.fdExhausted.Swap(true) {
+		p.logf("grpc_pool: file descriptors exhausted, stop dialing %v but a probe every %v until a connection closed: %v", p.addr, fdProbeBackoff, err)
+	}
+}
+
+// fdBlocked report whether dials are stopped as file descriptors exhausted.
+// One probe dial is let through every fdProbeBackoff, as descriptors freed
+// elsewhere in the process are never seen by the pool, e.g. one without
+// connections to close. p MUST be locked.
+func (p *GRpcClientPool) fdBlocked() bool {
+	if !p.fdExhausted.Load() {
+		return false
+	}
+
+	now := time.Now()
+	if now.Before(p.fdProbeAt) {
+		return true
+	}
+	p.fdProbeAt = now.Add(fdProbeBackoff)
+
+	return false
+}
+
+// noteFDFreed leave degraded mode as a connection closed or a dial succeeded,
+// p MUST be locked
+func (p *GRpcClientPool) noteFDFreed() {
+	if p.fdExhausted.Swap(false) {
+		p.logf("grpc_pool: file descriptor freed, resume dialing %v", p.addr)
+	}
+}
+
+// watchFD wrap the dialer of WithContextDialer to note its errors, so file
+// descriptors exhausted are detected on lazy connects too
+func (p *GRpcClientPool) watchFD(d func(ctx context.Context, addr string) (net.Conn, error)) func(ctx context.Context, addr string) (net.Conn, error) {
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := d(ctx, addr)
+		p.Lock()
+		if err != nil {
+			p.noteDialError(err)
+		} else {
+			p.noteFDFreed()
+		}
+		p.Unlock()
+		return conn, err
+	}
+}
@@ -0,0 +1,115 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestFDExhausted(t *testing.T) {
+	var exhausted atomic.Bool
+	dialF := newTestServer(t).DialFunc()
+	p, _ := NewGRpcClientPoolE("bufnet", func(addr string) (*grpc.ClientConn, error) {
+		if exhausted.Load() {
+			return nil, fmt.Errorf("dial: %w", os.NewSyscallError("socket", syscall.EMFILE))
+		}
+		return dialF(addr)
+	}, 5, time.Minute)
+	defer p.Release()
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	exhausted.Store(true)
+	c, _ := p.Get()
+	if _, err := p.Get(); err == nil || !p.Stats().FDExhausted {
+		t.Fatalf("want file descriptors exhausted detected, got %v", err)
+	}
+
+	// no dial in degraded mode, existing clients served
+	exhausted.Store(false)
+	if _, err := p.Get(); err != ERROR_FD_EXHAUSTED {
+		t.Fatalf("want ERROR_FD_EXHAUSTED, got %v", err)
+	}
+	p.Put(c)
+	if d, err := p.Get(); err != nil || d != c {
+		t.Fatalf("want the idle client, got %v", err)
+	}
+
+	// a close frees one
+	p.DelErrorClient(b)
+	if p.Stats().FDExhausted {
+		t.Fatal("want degraded mode left once a client closed")
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFDExhaustedProbe(t *testing.T) {
+	var exhausted atomic.Bool
+	exhausted.Store(true)
+	dialF := newTestServer(t).DialFunc()
+	p, _ := NewGRpcClientPoolE("bufnet", func(addr string) (*grpc.ClientConn, error) {
+		if exhausted.Load() {
+			return nil, fmt.Errorf("dial: %w", os.NewSyscallError("socket", syscall.EMFILE))
+		}
+		return dialF(addr)
+	}, 5, time.Minute)
+	defer p.Release()
+
+	// nothing to close, only a probe after the backoff recovers
+	p.Get()
+	if _, err := p.Get(); err != ERROR_FD_EXHAUSTED || p.Stats().Count != 0 {
+		t.Fatalf("want ERROR_FD_EXHAUSTED, got %v", err)
+	}
+	probe := func() {
+		p.Lock()
+		p.fdProbeAt = time.Now()
+		p.Unlock()
+	}
+
+	// the probe failed, backed off again
+	probe()
+	if _, err := p.Get(); errors.Is(err, ERROR_FD_EXHAUSTED) || !p.Stats().FDExhausted {
+		t.Fatalf("want the probe dialed and failed, got %v", err)
+	}
+	if _, err := p.Get(); err != ERROR_FD_EXHAUSTED {
+		t.Fatalf("want ERROR_FD_EXHAUSTED, got %v", err)
+	}
+
+	exhausted.Store(false)
+	probe()
+	if _, err := p.Get(); err != nil || p.Stats().FDExhausted {
+		t.Fatalf("want degraded mode left by the probe, got %v", err)
+	}
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFDExhaustedContextDialer(t *testing.T) {
+	srv := newTestServer(t)
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return nil, os.NewSyscallError("socket", syscall.EMFILE)
+	}
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithContextDialer(dialer))
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// connected lazily by gRPC with the dialer
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	c.GetConn().Invoke(ctx, "/unknown.Service/Method", nil, nil)
+	waitUntil(t, func() bool { return p.Stats().FDExhausted })
+}
@@ -0,0 +1,49 @@
+package grpc_pool
+
+import (
+	"context"
+)
+
+// CloseGracefully stop handing out connections, close idle ones, and wait for
+// checked out ones given back before Release, or release at once when ctx
+// done and return its error. Connections are closed without validation while
+// closing, and the closes are intentional so not reported as errors, see
+// ShutdownCollector. Get return ERROR_POOL_CLOSED since.
+//
+// progress, if not nil, is called with the number of connections checked out
+// or being dialed left whenever one is given back or closed while waiting.
+// It's called with the pool locked, so MUST NOT call back into the pool.
+func (p *GRpcClientPool) CloseGracefully(ctx context.Context, progress func(remaining int)) error {
+	p.Lock()
+	p.closing.Store(true)
+
+	for _, c := range p.pool.clear() {
+		p.retire(c)
+	}
+	p.idlePeak = 0
+
+	p.drainProgress = progress
+	err := p.waitReturned(ctx)
+	p.drainProgress = nil
+	p.release()
+	p.Unlock()
+
+	p.wg.Wait()
+
+	return err
+}
+
+// waitReturned block until no conn is checked out or being dialed, or ctx
+// done, p MUST be locked
+func (p *GRpcClientPool) waitReturned(ctx context.Context) error {
+	defer p.wakeOnDone(ctx)()
+
+	for len(p.out) > 0 || len(p.dialing) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.cond.Wait()
+	}
+
+	return nil
+}
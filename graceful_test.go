@@ -0,0 +1,124 @@
+package grpc_pool
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countLogger count log lines
+type countLogger struct{ n atomic.Int32 }
+
+func (l *countLogger) Printf(string, ...interface{}) { l.n.Add(1) }
+
+func TestCloseGracefullyNoValidation(t *testing.T) {
+	var checks atomic.Int32
+	check := func(ctx context.Context, c *IdleClient) error {
+		if c.owner.closing.Load() {
+			checks.Add(1)
+		}
+		return nil
+	}
+	col, logs := &closeCollector{}, &countLogger{}
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithCollector(col), WithLogger(logs),
+		WithHealthCheck(check), WithKeepWarm(5*time.Millisecond))
+
+	var cs []*IdleClient
+	for i := 0; i < 3; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	p.Put(cs[0])
+
+	done := make(chan error)
+	go func() { done <- p.CloseGracefully(context.Background(), nil) }()
+	waitUntil(t, func() bool { return p.closing.Load() })
+	if _, err := p.Get(); err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED while closing, got %v", err)
+	}
+	p.Put(cs[1])
+	p.DelErrorClient(cs[2])
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n := checks.Load(); n != 0 {
+		t.Fatalf("want no validation while closing, got %v", n)
+	}
+	p.Lock()
+	errs, shutdowns := len(col.errs), col.shutdowns
+	p.Unlock()
+	if errs != 0 || shutdowns != 3 || logs.n.Load() != 0 {
+		t.Fatalf("want 3 intentional closes and nothing logged, got %v closes, %v shutdowns and %v lines", errs, shutdowns, logs.n.Load())
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want all closed, got %+v", s)
+	}
+}
+
+func TestCloseGracefullyCtxDone(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	p.Get()
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := p.CloseGracefully(ctx, nil); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded with a client held, got %v", err)
+	}
+}
+
+func TestCloseGracefullyProgress(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	var cs []*IdleClient
+	for i := 0; i < 3; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	p.Put(cs[2])
+
+	// progress is called with the pool locked
+	var remaining []int
+	done := make(chan error)
+	go func() {
+		done <- p.CloseGracefully(context.Background(), func(n int) { remaining = append(remaining, n) })
+	}()
+	waitUntil(t, func() bool { return p.closing.Load() })
+	p.Put(cs[0])
+	p.DelErrorClient(cs[1])
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if len(remaining) != 2 || remaining[0] != 1 || remaining[1] != 0 {
+		t.Fatalf("want a countdown per client given back, got %v", remaining)
+	}
+}
+
+func TestPutDuringCloseGracefully(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	done := make(chan error)
+	go func() { done <- p.CloseGracefully(context.Background(), nil) }()
+	waitUntil(t, func() bool { return p.closing.Load() })
+
+	if err := p.Put(a); err != nil {
+		t.Fatal(err)
+	}
+	if !isClosed(p, a) {
+		t.Fatal("want the client closed rather than pooled")
+	}
+	p.Put(b)
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("want the drain completed")
+	}
+}
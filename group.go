@@ -0,0 +1,82 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+)
+
+// PoolGroup fail over across ordered tiers of pools, e.g. a primary and a
+// secondary backend cluster: Get try the primary, and the next tier only if
+// it's exhausted or can't be dialed
+type PoolGroup struct {
+	tiers []*GRpcClientPool
+
+	// Gets served by each tier
+	served []atomic.Int64
+}
+
+// NewPoolGroup create a group of tiers, first the primary
+func NewPoolGroup(tiers ...*GRpcClientPool) *PoolGroup {
+	return &PoolGroup{
+		tiers:  tiers,
+		served: make([]atomic.Int64, len(tiers)),
+	}
+}
+
+// Get return a connection from the first tier able to give one
+func (g *PoolGroup) Get() (*IdleClient, error) {
+	return g.GetContext(context.Background())
+}
+
+// GetContext is like Get, ctx is passed to GetContext of tiers. The error of
+// the last tier is returned if none can give a connection. Other errors
+// than capacity, dial and unavailable tier errors, e.g. ctx done, are
+// returned right away.
+func (g *PoolGroup) GetContext(ctx context.Context) (*IdleClient, error) {
+	err := errors.New("No tier in pool group")
+	for i, p := range g.tiers {
+		var c *IdleClient
+		if c, err = p.GetContext(ctx); err == nil {
+			g.served[i].Add(1)
+			return c, nil
+		}
+		if !IsCapacityError(err) && !IsDialError(err) && !tierUnavailable(err) {
+			return nil, err
+		}
+	}
+
+	return nil, err
+}
+
+// Put give back c to the tier it's taken from
+func (g *PoolGroup) Put(c *IdleClient) error {
+	if c == nil {
+		return ERROR_NIL_CLIENT
+	}
+	return c.owner.Put(c)
+}
+
+// DelErrorClient retire c in the tier it's taken from
+func (g *PoolGroup) DelErrorClient(c *IdleClient) {
+	if c != nil {
+		c.owner.DelErrorClient(c)
+	}
+}
+
+// Served return the number of Gets served by each tier, in tier order
+func (g *PoolGroup) Served() []int64 {
+	served := make([]int64, len(g.served))
+	for i := range g.served {
+		served[i] = g.served[i].Load()
+	}
+	return served
+}
+
+// tierUnavailable report whether err means the tier gives no connection at
+// all for now, e.g. paused or closed
+func tierUnavailable(err error) bool {
+	return errors.Is(err, ERROR_POOL_DRAINING) ||
+		errors.Is(err, ERROR_POOL_PAUSED) ||
+		errors.Is(err, ERROR_POOL_CLOSED)
+}
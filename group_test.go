@@ -0,0 +1,88 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestPoolGroupFailover(t *testing.T) {
+	srv := newTestServer(t)
+	primary := newTestPool(t, srv, 1, time.Minute)
+	secondary := newTestPool(t, srv, 1, time.Minute)
+	g := NewPoolGroup(primary, secondary)
+
+	c1, _ := g.Get()
+	c2, err := g.Get()
+	if err != nil || c1.owner != primary || c2.owner != secondary {
+		t.Fatalf("want the secondary used once the primary exhausted, got %v", err)
+	}
+	if _, err := g.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want the error of the last tier, got %v", err)
+	}
+	if s := g.Served(); s[0] != 1 || s[1] != 1 {
+		t.Fatalf("want 1 Get served by each tier, got %v", s)
+	}
+
+	g.Put(c2)
+	if s := secondary.Stats(); s.Idle != 1 {
+		t.Fatalf("want c2 given back to the secondary, got %+v", s)
+	}
+}
+
+func TestPoolGroupDialFailed(t *testing.T) {
+	down := NewGRpcClientPool("down", func(string) (*grpc.ClientConn, error) { return nil, errors.New("down") }, 1, time.Minute)
+	defer down.Release()
+	secondary := newTestPool(t, newTestServer(t), 1, time.Minute)
+
+	if c, err := NewPoolGroup(down, secondary).Get(); err != nil || c.owner != secondary {
+		t.Fatalf("want the secondary used on dial failure, got %v", err)
+	}
+}
+
+func TestPoolGroupUnavailable(t *testing.T) {
+	srv := newTestServer(t)
+	primary := newTestPool(t, srv, 1, time.Minute)
+	secondary := newTestPool(t, srv, 5, time.Minute)
+	g := NewPoolGroup(primary, secondary)
+
+	for _, tt := range []struct {
+		name         string
+		down, revive func()
+	}{
+		{"paused", primary.Pause, primary.Resume},
+		{"draining", func() { primary.setDraining(true) }, func() { primary.setDraining(false) }},
+		{"closed", primary.Release, func() {}},
+	} {
+		tt.down()
+		if c, err := g.Get(); err != nil || c.owner != secondary {
+			t.Errorf("want the secondary used when the primary %v, got %v", tt.name, err)
+		}
+		tt.revive()
+	}
+}
+
+func TestPoolGroupCtxDone(t *testing.T) {
+	srv := newTestServer(t)
+	primary := newTestPool(t, srv, 1, time.Minute, WithPauseBlocks())
+	secondary := newTestPool(t, srv, 1, time.Minute)
+	primary.Pause()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := NewPoolGroup(primary, secondary).GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want ctx done returned without failing over, got %v", err)
+	}
+	if s := secondary.Stats(); s.Count != 0 {
+		t.Fatalf("want the secondary untouched, got %+v", s)
+	}
+}
+
+func TestPoolGroupEmpty(t *testing.T) {
+	if _, err := NewPoolGroup().Get(); err == nil {
+		t.Fatal("want an error without tiers")
+	}
+}
@@ -0,0 +1,56 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+)
+
+// GetHedged race GetReady on the pools of addrs and return the first ready
+// connection with its address, cutting the tail latency of a slow backend.
+// The other Gets are cancelled, and connections they got anyway are given
+// back. If all fail the last error is returned.
+func (mp *MapPool) GetHedged(ctx context.Context, addrs []string) (string, *IdleClient, error) {
+	if len(addrs) == 0 {
+		return "", nil, errors.New("No address to get from")
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		addr string
+		p    *GRpcClientPool
+		c    *IdleClient
+		err  error
+	}
+	ch := make(chan result, len(addrs))
+	for _, addr := range addrs {
+		p := mp.GetPool(addr)
+		go func(addr string) {
+			c, err := p.GetReady(ctx)
+			ch <- result{addr, p, c, err}
+		}(addr)
+	}
+
+	var err error
+	for i := range addrs {
+		r := <-ch
+		if r.err != nil {
+			err = r.err
+			continue
+		}
+
+		// give back the losers once they done
+		cancel()
+		go func(n int) {
+			for ; n > 0; n-- {
+				if r := <-ch; r.c != nil {
+					r.p.Put(r.c)
+				}
+			}
+		}(len(addrs) - 1 - i)
+		return r.addr, r.c, nil
+	}
+
+	return "", nil, err
+}
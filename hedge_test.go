@@ -0,0 +1,44 @@
+package grpc_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestGetHedged(t *testing.T) {
+	dialF := newTestServer(t).DialFunc()
+	mp := NewMapPool(dialF, 3, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	dialed := make(chan struct{})
+	mp.SetDialFunc("slow", func(addr string) (*grpc.ClientConn, error) {
+		defer close(dialed)
+		time.Sleep(200 * time.Millisecond)
+		return dialF(addr)
+	})
+
+	addr, c, err := mp.GetHedged(context.Background(), []string{"slow", "fast"})
+	if err != nil || addr != "fast" || c == nil {
+		t.Fatalf("want the fast one, got %v, %v", addr, err)
+	}
+
+	// the loser is given back once dialed
+	<-dialed
+	slow := mp.GetPool("slow")
+	waitUntil(t, func() bool {
+		s := slow.Stats()
+		return s.InUse == 0 && s.Count == s.Idle
+	})
+}
+
+func TestGetHedgedNoAddress(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 3, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	if _, _, err := mp.GetHedged(context.Background(), nil); err == nil {
+		t.Fatal("want an error without addresses")
+	}
+}
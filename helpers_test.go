@@ -0,0 +1,77 @@
+package grpc_pool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// newTestServer start an in-memory server serving the health service, it's
+// stopped when the test done
+func newTestServer(t testing.TB) *testutil.Server {
+	srv := testutil.NewServer(func(s *grpc.Server) {
+		healthpb.RegisterHealthServer(s, health.NewServer())
+	})
+	t.Cleanup(srv.Stop)
+	return srv
+}
+
+// newTestPool create a pool dialing srv, it's released when the test done
+func newTestPool(t testing.TB, srv *testutil.Server, maxCount int, idleTimeout time.Duration, opts ...Option) *GRpcClientPool {
+	p, err := NewGRpcClientPoolE("bufnet", srv.DialFunc(), maxCount, idleTimeout, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(p.Release)
+	return p
+}
+
+// checkHealth call the health service on c, connecting it if Idle
+func checkHealth(c *IdleClient) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := healthpb.NewHealthClient(c.GetConn()).Check(ctx, &healthpb.HealthCheckRequest{})
+	return err
+}
+
+// waitUntil poll cond until true, fail the test after a second
+func waitUntil(t testing.TB, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("condition not met in time")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// logBuffer collect log lines of a pool, safe for background goroutines
+type logBuffer struct {
+	mu  sync.Mutex
+	buf strings.Builder
+}
+
+func (b *logBuffer) Printf(format string, v ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fmt.Fprintf(&b.buf, format+"\n", v...)
+}
+
+func (b *logBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
@@ -0,0 +1,45 @@
+package grpc_pool
+
+import (
+	"fmt"
+)
+
+// WithHighWaterCallback call fn when count rise to threshold of maxCount or
+// above, e.g. 0.8 for early warning before the pool is exhausted, and again
+// when it fall back below. fn is called with the pool locked, so MUST NOT
+// call back into the pool. It needs maxCount set.
+func WithHighWaterCallback(threshold float64, fn func(count, max int)) Option {
+	return func(p *GRpcClientPool) {
+		if threshold <= 0 || threshold > 1 || fn == nil {
+			p.invalid(fmt.Errorf("Invalid high water threshold[%v]", threshold))
+			return
+		}
+		p.highWater, p.onHighWater = threshold, fn
+	}
+}
+
+// withHighWaterThreshold set the threshold of a callback set later by
+// WithHighWaterCallback, see Config.HighWaterThreshold
+func withHighWaterThreshold(threshold float64) Option {
+	return func(p *GRpcClientPool) {
+		if threshold <= 0 || threshold > 1 {
+			p.invalid(fmt.Errorf("Invalid high water threshold[%v]", threshold))
+			return
+		}
+		p.highWater = threshold
+	}
+}
+
+// checkHighWater call onHighWater if count crossed the high water mark since
+// last checked, p MUST be locked
+func (p *GRpcClientPool) checkHighWater() {
+	if p.onHighWater == nil || p.maxCount <= 0 {
+		return
+	}
+
+	above := float64(p.count) >= p.highWater*float64(p.maxCount)
+	if above != p.aboveHighWater {
+		p.aboveHighWater = above
+		p.onHighWater(p.count, p.maxCount)
+	}
+}
@@ -0,0 +1,73 @@
+package grpc_pool
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHighWaterCallback(t *testing.T) {
+	var got [][2]int
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithHighWaterCallback(0.8, func(count, max int) {
+		got = append(got, [2]int{count, max})
+	}))
+
+	var cs []*IdleClient
+	for i := 0; i < 5; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	if len(got) != 1 || got[0] != [2]int{4, 5} {
+		t.Fatalf("want one upward crossing at 4, got %v", got)
+	}
+
+	p.DelErrorClient(cs[0])
+	p.DelErrorClient(cs[1])
+	if len(got) != 2 || got[1] != [2]int{3, 5} {
+		t.Fatalf("want one recovery at 3, got %v", got)
+	}
+
+	p.Get()
+	if len(got) != 3 || got[2] != [2]int{4, 5} {
+		t.Fatalf("want crossing again, got %v", got)
+	}
+}
+
+func TestHighWaterBoost(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got [][2]int
+	)
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithHighWaterCallback(0.8, func(count, max int) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, [2]int{count, max})
+	}))
+	calls := func() [][2]int {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([][2]int(nil), got...)
+	}
+
+	for i := 0; i < 4; i++ {
+		p.Get()
+	}
+	p.BoostMaxCount(5, 50*time.Millisecond)
+	if c := calls(); len(c) != 2 || c[1] != [2]int{4, 10} {
+		t.Fatalf("want a recovery as boosted, got %v", c)
+	}
+
+	// the checked out clients are kept as the boost ends
+	waitUntil(t, func() bool { return len(calls()) == 3 })
+	if c := calls(); c[2] != [2]int{4, 5} {
+		t.Fatalf("want crossing again as the boost ended, got %v", c)
+	}
+}
+
+func TestHighWaterInvalid(t *testing.T) {
+	for _, threshold := range []float64{0, 2} {
+		if _, err := NewGRpcClientPoolE("bufnet", nil, 5, 0, WithHighWaterCallback(threshold, func(int, int) {})); err == nil {
+			t.Errorf("want threshold %v invalid", threshold)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package grpc_pool
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+)
+
+// HookFunc is called by the pool on a connection, ctx is the one passed to
+// GetContext or Warmup. A non-nil error means the connection is bad.
+type HookFunc func(ctx context.Context, c *IdleClient) error
+
+// WithoutContext adapt a hook not caring about context to HookFunc
+func WithoutContext(fn func(c *IdleClient) error) HookFunc {
+	return func(_ context.Context, c *IdleClient) error {
+		return fn(c)
+	}
+}
+
+// WithOnDial call fn on each new connection before it's used, e.g. to
+// authenticate. The connection is closed if fn return an error, and the
+// error is returned by Get.
+func WithOnDial(fn HookFunc) Option {
+	return func(p *GRpcClientPool) {
+		p.onDial = fn
+	}
+}
+
+// WithOnClose call fn on each connection just before it's closed, whatever
+// the reason, e.g. reaped, retired or the pool released. Its id and tags can
+// be read. It's called with the pool locked mostly, so MUST NOT call back
+// into the pool.
+func WithOnClose(fn func(c *IdleClient)) Option {
+	return func(p *GRpcClientPool) {
+		p.onClose = fn
+	}
+}
+
+// WithQuotaHook call fn before Get dial a new connection, e.g. to check a
+// quota shared by pools. If fn return an error Get return it without
+// dialing. Dials of Warmup, Start, MapPool.WarmupAll and the MinIdle top up
+// ask fn too, each denied one is skipped and its error joined. fn is called
+// without the pool locked, so it can be remote.
+func WithQuotaHook(fn func(addr string) error) Option {
+	return func(p *GRpcClientPool) {
+		p.quotaHook = fn
+	}
+}
+
+// askQuota consult the quota hook before a dial, p need not be locked
+func (p *GRpcClientPool) askQuota() error {
+	if p.quotaHook == nil {
+		return nil
+	}
+	return p.quotaHook(p.addr)
+}
+
+// WithDialFaultInjector call fn before every dial, FOR TESTING the error
+// handling of applications only. attempt counts dials of the pool from 1. If
+// fn return an error the dial fails with it as if the dial function did,
+// e.g. to fail every other dial:
+//
+//	WithDialFaultInjector(func(addr string, attempt int) error {
+//		if attempt%2 == 0 {
+//			return errors.New("injected")
+//		}
+//		return nil
+//	})
+func WithDialFaultInjector(fn func(addr string, attempt int) error) Option {
+	return func(p *GRpcClientPool) {
+		p.dialFault = fn
+	}
+}
+
+// WithHealthCheck call fn on idle connections before handing them out, bad
+// ones are closed and Get try another one.
+func WithHealthCheck(fn HookFunc) Option {
+	return func(p *GRpcClientPool) {
+		p.healthCheck = fn
+	}
+}
+
+// WithWarmupRPC call fn on each connection dialed by Warmup, e.g. issuing a
+// real rpc so the first rpc of users won't pay for connection setup and cold
+// caches of the server. The connection is discarded if fn return an error.
+// fn is also the rpc issued by WithKeepWarm.
+func WithWarmupRPC(fn func(ctx context.Context, cc *grpc.ClientConn) error) Option {
+	return func(p *GRpcClientPool) {
+		p.warmupRPC = fn
+	}
+}
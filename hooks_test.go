@@ -0,0 +1,231 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+type tenantKey struct{}
+
+func TestHooksGetContextValue(t *testing.T) {
+	var dialV, checkV atomic.Value
+	p := newTestPool(t, newTestServer(t), 5, time.Minute,
+		WithOnDial(func(ctx context.Context, c *IdleClient) error {
+			dialV.Store(ctx.Value(tenantKey{}))
+			return nil
+		}),
+		WithHealthCheck(func(ctx context.Context, c *IdleClient) error {
+			checkV.Store(ctx.Value(tenantKey{}))
+			return nil
+		}))
+	ctx := context.WithValue(context.Background(), tenantKey{}, "tenant")
+
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := dialV.Load(); v != "tenant" {
+		t.Fatalf("want the ctx value in OnDial, got %v", v)
+	}
+	p.Put(c)
+
+	if c2, _ := p.GetContext(ctx); c2 != c {
+		t.Fatal("want the idle client")
+	}
+	if v := checkV.Load(); v != "tenant" {
+		t.Fatalf("want the ctx value in HealthCheck, got %v", v)
+	}
+}
+
+func TestHealthCheckFailRetire(t *testing.T) {
+	var fail atomic.Bool
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithHealthCheck(func(context.Context, *IdleClient) error {
+		if fail.Load() {
+			return errors.New("bad")
+		}
+		return nil
+	}))
+
+	c, _ := p.Get()
+	p.Put(c)
+	fail.Store(true)
+	c2, err := p.Get()
+	if err != nil || c2 == c {
+		t.Fatalf("want a new client for the bad one, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 1 {
+		t.Fatalf("want the bad client retired, got %+v", s)
+	}
+}
+
+func TestHealthCheckCtxDone(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithHealthCheck(func(ctx context.Context, _ *IdleClient) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return nil
+		}
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	c, _ := p.Get()
+	p.Put(c)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	c2, err := p.GetContext(ctx)
+	if c2 != nil || err != context.DeadlineExceeded {
+		t.Fatalf("want no client and ctx error, got %v, %v", c2, err)
+	}
+	c3, err := p.GetNoDial(ctx)
+	if c3 != nil || err != context.DeadlineExceeded {
+		t.Fatalf("want no client and ctx error, got %v, %v", c3, err)
+	}
+	if s := p.Stats(); s.Idle != 1 || s.InUse != 0 {
+		t.Fatalf("want the client given back, got %+v", s)
+	}
+}
+
+func TestOnDialDeny(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithOnDial(WithoutContext(func(*IdleClient) error {
+		return errors.New("deny")
+	})))
+
+	if _, err := p.Get(); err == nil {
+		t.Fatal("want the dial denied")
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the denied client uncounted, got %+v", s)
+	}
+}
+
+func TestOnClose(t *testing.T) {
+	ct := &closeTracker{closed: make(map[*IdleClient]int)}
+	var early atomic.Int32
+	onClose := func(c *IdleClient) {
+		if c.GetConn().GetState() != connectivity.Shutdown && c.Tag("k") == "v" {
+			early.Add(1)
+		}
+		ct.onClose(c)
+	}
+	p := newTestPool(t, newTestServer(t), 10, 20*time.Millisecond, WithOnClose(onClose), WithMaxIdle(1))
+
+	var cs []*IdleClient
+	for i := 0; i < 4; i++ {
+		c, _ := p.Get()
+		c.SetTag("k", "v")
+		cs = append(cs, c)
+	}
+	p.Put(cs[0])
+	// discarded beyond maxIdle
+	p.Put(cs[1])
+	p.DelErrorClient(cs[2])
+	time.Sleep(30 * time.Millisecond)
+	// reap cs[0]
+	c, _ := p.Get()
+	c.SetTag("k", "v")
+	p.Put(c)
+	p.Release()
+	// given back after Release
+	p.Put(cs[3])
+
+	ct.check(t)
+	for _, c := range append(cs, c) {
+		if ct.closed[c] != 1 {
+			t.Fatalf("want OnClose called for client %v", c.ID())
+		}
+	}
+	if n := early.Load(); n != 5 {
+		t.Fatalf("want OnClose called before each close with tags, got %v", n)
+	}
+}
+
+func TestOnCloseGracefully(t *testing.T) {
+	var closes atomic.Int32
+	p := newTestPool(t, newTestServer(t), 10, time.Minute, WithOnClose(func(*IdleClient) { closes.Add(1) }))
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	time.AfterFunc(10*time.Millisecond, func() { p.Put(b) })
+	p.CloseGracefully(context.Background(), nil)
+	if n := closes.Load(); n != 2 {
+		t.Fatalf("want OnClose called for both clients, got %v", n)
+	}
+}
+
+func TestQuotaHook(t *testing.T) {
+	deny := errors.New("quota")
+	var allow atomic.Int32
+	allow.Store(1)
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithQuotaHook(func(addr string) error {
+		if addr != "bufnet" {
+			t.Errorf("want the address of the pool, got %v", addr)
+		}
+		if allow.Add(-1) < 0 {
+			return deny
+		}
+		return nil
+	}))
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.Get(); err != deny || p.Stats().Count != 1 {
+		t.Fatalf("want the quota error without counting, got %v, %+v", err, p.Stats())
+	}
+	if _, err := p.GetFresh(context.Background()); err != deny {
+		t.Fatalf("want GetFresh asking the quota, got %v", err)
+	}
+
+	// no dial, no quota asked
+	p.Put(c)
+	if d, err := p.Get(); err != nil || d != c {
+		t.Fatalf("want the idle client, got %v", err)
+	}
+}
+
+func TestQuotaHookWarmup(t *testing.T) {
+	deny := errors.New("quota")
+	var asked atomic.Int32
+	p := newTestPool(t, newTestServer(t), 10, time.Minute, WithMinIdle(4), WithQuotaHook(func(string) error {
+		if asked.Add(1) > 2 {
+			return deny
+		}
+		return nil
+	}))
+
+	if err := p.Warmup(context.Background()); !errors.Is(err, deny) {
+		t.Fatalf("want the quota error, got %v", err)
+	}
+	if s := p.Stats(); s.Idle != 2 || s.Count != 2 {
+		t.Fatalf("want the dials denied uncounted, got %+v", s)
+	}
+}
+
+func TestDialFaultInjector(t *testing.T) {
+	injected := errors.New("injected")
+	p := newTestPool(t, newTestServer(t), 10, time.Minute, WithDialFaultInjector(func(addr string, attempt int) error {
+		if addr != "bufnet" {
+			t.Errorf("want the address of the pool, got %v", addr)
+		}
+		if attempt%2 == 0 {
+			return injected
+		}
+		return nil
+	}))
+
+	for i := 1; i <= 6; i++ {
+		if _, err := p.Get(); (i%2 == 0) != errors.Is(err, injected) {
+			t.Fatalf("want every other dial failed, got %v at %v", err, i)
+		}
+	}
+	if s := p.Stats(); s.Count != 3 || s.DialErrors != 3 {
+		t.Fatalf("want the injected failures as dial errors, got %+v", s)
+	}
+}
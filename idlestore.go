@@ -0,0 +1,247 @@
+package grpc_pool
+
+import (
+	"container/heap"
+	"time"
+)
+
+// idleStore hold the idle clients of a pool with the deadline each becomes
+// stale at, see idleDeadline. Clients are indexed in the order of the store,
+// least recently given back first for sliceStore. p MUST be locked for all
+// methods.
+type idleStore interface {
+	size() int
+	at(i int) *IdleClient
+	contains(c *IdleClient) bool
+
+	push(c *IdleClient, deadline time.Time)
+	removeAt(i int) *IdleClient
+	remove(c *IdleClient) bool
+	// popExpired remove the clients stale at now, and return them and the
+	// earliest deadline left, zero if none left
+	popExpired(now time.Time) ([]*IdleClient, time.Time)
+
+	// rekey recompute the deadlines after the idle timeout changed
+	rekey(deadline func(c *IdleClient) time.Time)
+	// clear remove all clients and return them
+	clear() []*IdleClient
+	// compact reallocate the store to fit
+	compact()
+}
+
+// WithIdleHeap keep idle connections in a min-heap by the time they become
+// stale rather than a slice ordered by last use, so the reaper pops stale
+// ones in O(log n) instead of scanning, e.g. for pools of many thousands of
+// connections with WithMaxLifetime. WithIdleSelect is approximate then:
+// SelectFIFO pick the connection soonest stale, and SelectLIFO an arbitrary
+// one.
+func WithIdleHeap() Option {
+	return func(p *GRpcClientPool) {
+		p.pool = &heapStore{}
+	}
+}
+
+type idleEntry struct {
+	c        *IdleClient
+	deadline time.Time
+}
+
+// sliceStore keep idle clients in the order given back
+type sliceStore struct {
+	entries []idleEntry
+}
+
+func (s *sliceStore) size() int {
+	return len(s.entries)
+}
+
+func (s *sliceStore) at(i int) *IdleClient {
+	return s.entries[i].c
+}
+
+func (s *sliceStore) contains(c *IdleClient) bool {
+	return s.index(c) >= 0
+}
+
+func (s *sliceStore) index(c *IdleClient) int {
+	for i, e := range s.entries {
+		if e.c == c {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *sliceStore) push(c *IdleClient, deadline time.Time) {
+	s.entries = append(s.entries, idleEntry{c, deadline})
+}
+
+func (s *sliceStore) removeAt(i int) *IdleClient {
+	c := s.entries[i].c
+	if i == 0 {
+		s.entries[0] = idleEntry{}
+		s.entries = s.entries[1:]
+	} else {
+		copy(s.entries[i:], s.entries[i+1:])
+		s.entries[len(s.entries)-1] = idleEntry{}
+		s.entries = s.entries[:len(s.entries)-1]
+	}
+	return c
+}
+
+func (s *sliceStore) remove(c *IdleClient) bool {
+	i := s.index(c)
+	if i < 0 {
+		return false
+	}
+	s.removeAt(i)
+	return true
+}
+
+// popExpired scan all clients, they are ordered by last used time but not
+// by deadline
+func (s *sliceStore) popExpired(now time.Time) ([]*IdleClient, time.Time) {
+	var (
+		expired []*IdleClient
+		next    time.Time
+	)
+	live := s.entries[:0]
+	for _, e := range s.entries {
+		if !e.deadline.After(now) {
+			expired = append(expired, e.c)
+			continue
+		}
+		live = append(live, e)
+		if next.IsZero() || e.deadline.Before(next) {
+			next = e.deadline
+		}
+	}
+	for i := len(live); i < len(s.entries); i++ {
+		s.entries[i] = idleEntry{}
+	}
+	s.entries = live
+
+	return expired, next
+}
+
+func (s *sliceStore) rekey(deadline func(c *IdleClient) time.Time) {
+	for i := range s.entries {
+		s.entries[i].deadline = deadline(s.entries[i].c)
+	}
+}
+
+func (s *sliceStore) clear() []*IdleClient {
+	cs := make([]*IdleClient, len(s.entries))
+	for i, e := range s.entries {
+		cs[i] = e.c
+	}
+	s.entries = nil
+	return cs
+}
+
+func (s *sliceStore) compact() {
+	entries := make([]idleEntry, len(s.entries))
+	copy(entries, s.entries)
+	s.entries = entries
+}
+
+// heapStore keep idle clients in a min-heap by deadline, each client knows
+// its index in heapIndex
+type heapStore struct {
+	h idleHeap
+}
+
+func (s *heapStore) size() int {
+	return len(s.h)
+}
+
+func (s *heapStore) at(i int) *IdleClient {
+	return s.h[i].c
+}
+
+func (s *heapStore) contains(c *IdleClient) bool {
+	i := c.heapIndex
+	return i >= 0 && i < len(s.h) && s.h[i].c == c
+}
+
+func (s *heapStore) push(c *IdleClient, deadline time.Time) {
+	heap.Push(&s.h, idleEntry{c, deadline})
+}
+
+func (s *heapStore) removeAt(i int) *IdleClient {
+	return heap.Remove(&s.h, i).(idleEntry).c
+}
+
+func (s *heapStore) remove(c *IdleClient) bool {
+	if !s.contains(c) {
+		return false
+	}
+	s.removeAt(c.heapIndex)
+	return true
+}
+
+func (s *heapStore) popExpired(now time.Time) ([]*IdleClient, time.Time) {
+	var expired []*IdleClient
+	for len(s.h) > 0 && !s.h[0].deadline.After(now) {
+		expired = append(expired, heap.Pop(&s.h).(idleEntry).c)
+	}
+	if len(s.h) == 0 {
+		return expired, time.Time{}
+	}
+	return expired, s.h[0].deadline
+}
+
+func (s *heapStore) rekey(deadline func(c *IdleClient) time.Time) {
+	for i := range s.h {
+		s.h[i].deadline = deadline(s.h[i].c)
+	}
+	heap.Init(&s.h)
+}
+
+func (s *heapStore) clear() []*IdleClient {
+	cs := make([]*IdleClient, len(s.h))
+	for i, e := range s.h {
+		e.c.heapIndex = -1
+		cs[i] = e.c
+	}
+	s.h = nil
+	return cs
+}
+
+func (s *heapStore) compact() {
+	h := make(idleHeap, len(s.h))
+	copy(h, s.h)
+	s.h = h
+}
+
+// idleHeap implement heap.Interface for heapStore
+type idleHeap []idleEntry
+
+func (h idleHeap) Len() int {
+	return len(h)
+}
+
+func (h idleHeap) Less(i, j int) bool {
+	return h[i].deadline.Before(h[j].deadline)
+}
+
+func (h idleHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].c.heapIndex = i
+	h[j].c.heapIndex = j
+}
+
+func (h *idleHeap) Push(x interface{}) {
+	e := x.(idleEntry)
+	e.c.heapIndex = len(*h)
+	*h = append(*h, e)
+}
+
+func (h *idleHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	old[len(old)-1] = idleEntry{}
+	*h = old[:len(old)-1]
+	e.c.heapIndex = -1
+	return e
+}
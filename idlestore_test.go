@@ -0,0 +1,133 @@
+package grpc_pool
+
+import (
+	"sort"
+	"testing"
+	"time"
+)
+
+// stores return a new store of each implementation
+func stores() map[string]idleStore {
+	return map[string]idleStore{"slice": &sliceStore{}, "heap": &heapStore{}}
+}
+
+func TestIdleStore(t *testing.T) {
+	now := time.Now()
+	for name, s := range stores() {
+		t.Run(name, func(t *testing.T) {
+			var cs []*IdleClient
+			for i := 0; i < 6; i++ {
+				c := &IdleClient{id: uint64(i), heapIndex: -1}
+				cs = append(cs, c)
+				// 0, 2 and 4 stale
+				d := now.Add(time.Duration(i+1) * time.Minute)
+				if i%2 == 0 {
+					d = now.Add(-time.Duration(i+1) * time.Minute)
+				}
+				s.push(c, d)
+			}
+			if s.size() != 6 || !s.contains(cs[5]) || s.contains(&IdleClient{heapIndex: -1}) {
+				t.Fatalf("want all clients held, got %v", s.size())
+			}
+
+			expired, next := s.popExpired(now)
+			sort.Slice(expired, func(i, j int) bool { return expired[i].id < expired[j].id })
+			if len(expired) != 3 || expired[0] != cs[0] || expired[1] != cs[2] || expired[2] != cs[4] {
+				t.Fatalf("want 0, 2 and 4 expired, got %v", expired)
+			}
+			if !next.Equal(now.Add(2 * time.Minute)) {
+				t.Fatalf("want the earliest deadline left, got %v", next)
+			}
+			if s.size() != 3 || s.contains(cs[0]) {
+				t.Fatal("want the expired removed")
+			}
+
+			if !s.remove(cs[1]) || s.remove(cs[1]) || s.size() != 2 {
+				t.Fatal("want cs[1] removed once")
+			}
+
+			// new deadlines, 5 stale now
+			s.rekey(func(c *IdleClient) time.Time {
+				if c == cs[5] {
+					return now
+				}
+				return now.Add(time.Hour)
+			})
+			if expired, next := s.popExpired(now); len(expired) != 1 || expired[0] != cs[5] || !next.Equal(now.Add(time.Hour)) {
+				t.Fatalf("want 5 expired by the new deadline, got %v, %v", expired, next)
+			}
+
+			s.compact()
+			if s.size() != 1 || s.at(0) != cs[3] {
+				t.Fatal("want cs[3] kept by compact")
+			}
+			if left := s.clear(); len(left) != 1 || left[0] != cs[3] || s.size() != 0 {
+				t.Fatalf("want cs[3] cleared, got %v", left)
+			}
+			if _, next := s.popExpired(now); !next.IsZero() {
+				t.Fatalf("want no deadline when empty, got %v", next)
+			}
+		})
+	}
+}
+
+func TestIdleStorePool(t *testing.T) {
+	srv := newTestServer(t)
+	for name, opts := range map[string][]Option{"slice": nil, "heap": {WithIdleHeap()}} {
+		t.Run(name, func(t *testing.T) {
+			p := newTestPool(t, srv, 10, time.Hour, append(opts, WithMaxLifetime(time.Hour))...)
+
+			var cs []*IdleClient
+			for i := 0; i < 6; i++ {
+				c, _ := p.Get()
+				cs = append(cs, c)
+			}
+			p.PutAll(cs)
+
+			// outlive 0, 2 and 4
+			p.Lock()
+			for _, c := range []*IdleClient{cs[0], cs[2], cs[4]} {
+				c.createdTime = time.Now().Add(-2 * time.Hour)
+			}
+			p.pool.rekey(p.idleDeadline)
+			p.nextExpire = time.Time{}
+			p.delStaleClients()
+			n := p.pool.size()
+			p.Unlock()
+			if n != 3 || !isClosed(p, cs[0]) || isClosed(p, cs[1]) {
+				t.Fatalf("want the outlived closed, got %v idle", n)
+			}
+
+			if !p.Evict(cs[3]) || p.Stats().Idle != 2 {
+				t.Fatalf("want cs[3] evicted, got %+v", p.Stats())
+			}
+			if c, _ := p.Get(); c != cs[1] && c != cs[5] {
+				t.Fatal("want an idle client left")
+			}
+		})
+	}
+}
+
+// benchmarkReap reap one client stale among 10k idle ones in store s
+func benchmarkReap(b *testing.B, s idleStore) {
+	p := &GRpcClientPool{pool: s, idleTimeout: time.Hour, maxLifetime: 2 * time.Hour}
+	now := time.Now()
+	for i := 0; i < 10000; i++ {
+		c := &IdleClient{lastCalledTime: now, createdTime: now.Add(time.Duration(i) * time.Second), heapIndex: -1}
+		p.pool.push(c, p.idleDeadline(c))
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := p.pool.removeAt(0)
+		p.pool.push(c, now.Add(-time.Second))
+		p.pool.popExpired(now)
+		p.pool.push(c, p.idleDeadline(c))
+	}
+}
+
+func BenchmarkReap(b *testing.B) {
+	for name, s := range stores() {
+		b.Run(name, func(b *testing.B) { benchmarkReap(b, s) })
+	}
+}
@@ -0,0 +1,39 @@
+package grpc_pool
+
+import (
+	"math/rand"
+	"time"
+)
+
+// WithReconnectJitter delay the first redial of each pool after an outage,
+// a failed dial or a connection found broken, by a random duration up to
+// max, so pools don't redial a recovered backend all at once
+func WithReconnectJitter(max time.Duration) MapOption {
+	return func(mp *MapPool) {
+		mp.poolOpts = append(mp.poolOpts, withReconnectJitter(max))
+	}
+}
+
+// withReconnectJitter set the jitter of a single pool, see
+// Config.ReconnectJitter
+func withReconnectJitter(max time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.reconnectJitter = max
+	}
+}
+
+// markOutage note a dial failed or a conn broke, p MUST be locked
+func (p *GRpcClientPool) markOutage() {
+	p.outage = p.reconnectJitter > 0
+}
+
+// redialDelay return how long a dial starting now should wait, the first one
+// after an outage is delayed by jitter, p MUST be locked
+func (p *GRpcClientPool) redialDelay() time.Duration {
+	if !p.outage {
+		return 0
+	}
+	p.outage = false
+
+	return time.Duration(rand.Int63n(int64(p.reconnectJitter)))
+}
@@ -0,0 +1,74 @@
+package grpc_pool
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestReconnectJitter(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	var (
+		mu    sync.Mutex
+		down  = true
+		times []time.Time
+	)
+	flaky := func(addr string) (*grpc.ClientConn, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if down {
+			return nil, errors.New("down")
+		}
+		times = append(times, time.Now())
+		return dial(addr)
+	}
+	mp := NewMapPool(flaky, 5, time.Minute, WithReconnectJitter(200*time.Millisecond))
+	defer mp.ReleaseAllPool()
+
+	var addrs []string
+	for i := 0; i < 10; i++ {
+		addrs = append(addrs, string(rune('a'+i)))
+		mp.GetPool(addrs[i]).Get()
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, addr := range addrs {
+		wg.Add(1)
+		go func(addr string) {
+			defer wg.Done()
+			if _, err := mp.GetPool(addr).Get(); err != nil {
+				t.Error(err)
+			}
+		}(addr)
+	}
+	wg.Wait()
+
+	min, max := time.Hour, time.Duration(0)
+	for _, tm := range times {
+		d := tm.Sub(start)
+		if d < min {
+			min = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	if max-min < 50*time.Millisecond || max > 300*time.Millisecond {
+		t.Fatalf("want redials spread over the jitter, got from %v to %v", min, max)
+	}
+
+	// only the first redial after the outage is delayed
+	start = time.Now()
+	mp.GetPool("a").Get()
+	if d := time.Since(start); d > 50*time.Millisecond {
+		t.Fatalf("want the next dial not delayed, took %v", d)
+	}
+}
@@ -0,0 +1,64 @@
+package grpc_pool
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// minKeepaliveTime is the least ping interval gRPC allows, smaller values are
+// raised to it silently
+const minKeepaliveTime = 10 * time.Second
+
+// WithKeepaliveTime ping the server after d without activity, so a hung
+// backend is detected. It's dialed by keepalive.ClientParameters, so needs a
+// DialOptionsFunc as WithDialOptions.
+func WithKeepaliveTime(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if d < minKeepaliveTime {
+			p.invalid(fmt.Errorf("Keepalive time[%v] less than %v", d, minKeepaliveTime))
+			return
+		}
+		p.keepaliveParams().Time = d
+	}
+}
+
+// WithKeepaliveTimeout close the connection if a keepalive ping isn't acked
+// within d
+func WithKeepaliveTimeout(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if d <= 0 {
+			p.invalid(fmt.Errorf("Keepalive timeout[%v] not positive", d))
+			return
+		}
+		p.keepaliveParams().Timeout = d
+	}
+}
+
+// WithKeepalivePermitWithoutStream send keepalive pings even if there is no
+// active rpc, needed to detect hung idle connections
+func WithKeepalivePermitWithoutStream() Option {
+	return func(p *GRpcClientPool) {
+		p.keepaliveParams().PermitWithoutStream = true
+	}
+}
+
+// keepaliveParams return the keepalive parameters set by options, creating
+// them with gRPC defaults
+func (p *GRpcClientPool) keepaliveParams() *keepalive.ClientParameters {
+	if p.keepalive == nil {
+		p.keepalive = &keepalive.ClientParameters{}
+	}
+	return p.keepalive
+}
+
+// keepaliveDialOption return the dial option of keepalive parameters set, nil
+// if none
+func (p *GRpcClientPool) keepaliveDialOption() grpc.DialOption {
+	if p.keepalive == nil {
+		return nil
+	}
+	return grpc.WithKeepaliveParams(*p.keepalive)
+}
@@ -0,0 +1,49 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestKeepaliveOptions(t *testing.T) {
+	srv := newTestServer(t)
+	var got []grpc.DialOption
+	dial := func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		got = opts
+		return srv.DialOptionsFunc()(addr, opts...)
+	}
+	p := newTestPool(t, srv, 2, time.Minute, WithDialOptionsFunc(dial),
+		WithKeepaliveTime(20*time.Second), WithKeepaliveTimeout(time.Second), WithKeepalivePermitWithoutStream())
+
+	if ka := p.keepalive; ka.Time != 20*time.Second || ka.Timeout != time.Second || !ka.PermitWithoutStream {
+		t.Fatalf("want the keepalive parameters set, got %+v", ka)
+	}
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("want the keepalive dial option, got %v", got)
+	}
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestKeepaliveInvalid(t *testing.T) {
+	for _, opt := range []Option{
+		WithKeepaliveTime(time.Second),
+		WithKeepaliveTimeout(0),
+	} {
+		if _, err := NewGRpcClientPoolE("bufnet", nil, 2, 0, opt); err == nil {
+			t.Error("want the keepalive option invalid")
+		}
+	}
+
+	dial := newTestServer(t).DialFunc()
+	if _, err := NewGRpcClientPoolE("bufnet", dial, 2, 0, WithKeepaliveTimeout(time.Second)); err != ERROR_DIAL_OPTIONS_IGNORED {
+		t.Fatalf("want ERROR_DIAL_OPTIONS_IGNORED, got %v", err)
+	}
+}
@@ -0,0 +1,110 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// WithKeepWarm issue the warmup rpc, or the health check if no warmup rpc is
+// set, on idle connections every interval, so servers and middleboxes won't
+// drop them as idle. Unlike keepalive pings it's an application level rpc.
+// Checked out connections are skipped, a connection is checked out during the
+// rpc so Get won't take it, and a connection failing it is evicted. Idle
+// timeout still counts from the last Put.
+func WithKeepWarm(interval time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.keepWarm = interval
+	}
+}
+
+// checkKeepWarm record an error if keep warm has no rpc to issue
+func (p *GRpcClientPool) checkKeepWarm() {
+	if p.keepWarm > 0 && p.warmupRPC == nil && p.healthCheck == nil {
+		p.invalid(errors.New("Keep warm needs a warmup rpc or health check"))
+	}
+}
+
+// keepWarmer keep idle clients warm every keepWarm until the pool released
+func (p *GRpcClientPool) keepWarmer() {
+	t := time.NewTicker(p.keepWarm)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.warmIdle()
+		}
+	}
+}
+
+// warmIdle issue the warm rpc on clients idle now, each checked out for the
+// rpc so Get won't take it meanwhile, the rpc is cancelled by Release
+func (p *GRpcClientPool) warmIdle() {
+	p.Lock()
+	idle := p.idleClients()
+	p.Unlock()
+
+	for _, c := range idle {
+		if p.closing.Load() {
+			return
+		}
+		if !p.checkoutWarm(c) {
+			// taken by Get or gone meanwhile
+			continue
+		}
+
+		ctx, cancel := context.WithTimeout(p.ctx, p.keepWarm)
+		var err error
+		if p.warmupRPC != nil {
+			err = p.warmupRPC(ctx, c.GetConn())
+		} else {
+			err = p.healthCheck(ctx, c)
+		}
+		cancel()
+		if err != nil && p.ctx.Err() == nil {
+			p.logClient(c, "grpc_pool: evict client failing keep warm: %v", err)
+		}
+
+		p.putWarm(c, err)
+		if p.ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// checkoutWarm check out c if it's still idle, without counting a use
+func (p *GRpcClientPool) checkoutWarm(c *IdleClient) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if !p.removeIdle(c) {
+		return false
+	}
+	c.checkoutTime = time.Now()
+	p.out[c] = struct{}{}
+
+	return true
+}
+
+// putWarm give back c checked out by checkoutWarm, idle timeout still counts
+// from the last Put. c is retired if the rpc failed with err.
+func (p *GRpcClientPool) putWarm(c *IdleClient, err error) {
+	p.Lock()
+	defer p.unlockReturned()
+
+	if _, ok := p.out[c]; !ok {
+		// reclaimed or released meanwhile
+		p.disown(c)
+		return
+	}
+	delete(p.out, c)
+	c.resetCheckout()
+	if err != nil || c.retireOnReturn || p.closing.Load() || p.draining || p.expired(c) {
+		p.retire(c)
+		return
+	}
+	p.addIdle(c)
+}
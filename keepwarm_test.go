@@ -0,0 +1,117 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestKeepWarm(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		warms = make(map[*grpc.ClientConn]int)
+	)
+	warm := func(ctx context.Context, conn *grpc.ClientConn) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		warms[conn]++
+		return nil
+	}
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithWarmupRPC(warm), WithKeepWarm(20*time.Millisecond))
+
+	idle, _ := p.Get()
+	out, _ := p.Get()
+	p.Put(idle)
+	waitUntil(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return warms[idle.GetConn()] >= 3
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+	if n := warms[out.GetConn()]; n != 0 {
+		t.Fatalf("want the checked out client skipped, got %v warms", n)
+	}
+}
+
+func TestKeepWarmNeedRPC(t *testing.T) {
+	if _, err := NewGRpcClientPoolE("bufnet", newTestServer(t).DialFunc(), 1, 0, WithKeepWarm(time.Second)); err == nil {
+		t.Fatal("want keep warm without rpc invalid")
+	}
+}
+
+func TestKeepWarmEvictFailing(t *testing.T) {
+	cold := WithoutContext(func(*IdleClient) error { return errors.New("cold") })
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithHealthCheck(cold), WithKeepWarm(10*time.Millisecond))
+
+	c, _ := p.Get()
+	p.Put(c)
+	waitUntil(t, func() bool { return p.Stats().Count == 0 })
+}
+
+func TestKeepWarmCancelledOnRelease(t *testing.T) {
+	var once sync.Once
+	started, errc := make(chan struct{}), make(chan error, 1)
+	warm := func(ctx context.Context, _ *grpc.ClientConn) error {
+		once.Do(func() { close(started) })
+		<-ctx.Done()
+		select {
+		case errc <- ctx.Err():
+		default:
+		}
+		return ctx.Err()
+	}
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithWarmupRPC(warm), WithKeepWarm(50*time.Millisecond))
+
+	c, _ := p.Get()
+	p.Put(c)
+	<-started
+	p.Release()
+
+	// cancelled rather than timed out by the keep warm interval
+	if err := <-errc; err != context.Canceled {
+		t.Fatalf("want the warm rpc cancelled by Release, got %v", err)
+	}
+}
+
+func TestKeepWarmChecksOut(t *testing.T) {
+	started, unblock := make(chan struct{}, 1), make(chan struct{})
+	warm := func(ctx context.Context, _ *grpc.ClientConn) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		select {
+		case <-unblock:
+		case <-ctx.Done():
+		}
+		return nil
+	}
+	p := newTestPool(t, newTestServer(t), 1, time.Minute, WithWarmupRPC(warm), WithKeepWarm(50*time.Millisecond))
+
+	c, _ := p.Get()
+	p.Put(c)
+	last := c.lastCalledTime
+	<-started
+	if s := p.Stats(); s.Idle != 0 || s.InUse != 1 {
+		t.Fatalf("want the client checked out during the warm rpc, got %+v", s)
+	}
+	if _, err := p.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want the client being warmed not taken by Get, got %v", err)
+	}
+
+	close(unblock)
+	waitUntil(t, func() bool { return p.Stats().Idle == 1 })
+	checkAccounting(t, p)
+	p.Lock()
+	defer p.Unlock()
+	if c.uses != 1 || !c.lastCalledTime.Equal(last) {
+		t.Fatalf("want no use counted nor idle timeout reset, got %v uses", c.uses)
+	}
+}
@@ -0,0 +1,97 @@
+package grpc_pool
+
+import (
+	"sync"
+	"time"
+)
+
+// Get latencies are counted in buckets whose upper bounds double from
+// latencyBase, and forgotten after two latencyWindow
+const (
+	latencyBase    = 50 * time.Microsecond
+	latencyBuckets = 24
+	latencyWindow  = time.Minute
+)
+
+// LatencyPercentiles of Get, including waiting and dialing. A percentile is
+// the upper bound of the bucket it falls in, so at most twice the real one.
+type LatencyPercentiles struct {
+	P50 time.Duration
+	P90 time.Duration
+	P99 time.Duration
+}
+
+// latencyHistogram count latencies of the current and the previous window
+type latencyHistogram struct {
+	cur, prev [latencyBuckets]int64
+	start     time.Time
+
+	sync.Mutex
+}
+
+// bucketOf return the bucket counting d
+func bucketOf(d time.Duration) int {
+	i := 0
+	for bound := latencyBase; d > bound && i < latencyBuckets-1; bound *= 2 {
+		i++
+	}
+	return i
+}
+
+// rotate start a new window if the current one is over, h MUST be locked
+func (h *latencyHistogram) rotate(now time.Time) {
+	switch elapsed := now.Sub(h.start); {
+	case elapsed < latencyWindow:
+		return
+	case elapsed < 2*latencyWindow:
+		h.prev = h.cur
+	default:
+		h.prev = [latencyBuckets]int64{}
+	}
+	h.cur = [latencyBuckets]int64{}
+	h.start = now
+}
+
+// record count latency d ended at now
+func (h *latencyHistogram) record(now time.Time, d time.Duration) {
+	h.Lock()
+	defer h.Unlock()
+
+	h.rotate(now)
+	h.cur[bucketOf(d)]++
+}
+
+// percentiles return percentiles of latencies in the current and previous
+// window, zero if none
+func (h *latencyHistogram) percentiles(now time.Time) LatencyPercentiles {
+	h.Lock()
+	defer h.Unlock()
+
+	h.rotate(now)
+	var (
+		counts [latencyBuckets]int64
+		total  int64
+	)
+	for i := range counts {
+		counts[i] = h.cur[i] + h.prev[i]
+		total += counts[i]
+	}
+	if total == 0 {
+		return LatencyPercentiles{}
+	}
+
+	at := func(q int64) time.Duration {
+		rank := (total*q + 99) / 100
+		var n int64
+		bound := latencyBase
+		for i := range counts {
+			if n += counts[i]; n >= rank {
+				break
+			}
+			bound *= 2
+		}
+		return bound
+	}
+
+	return LatencyPercentiles{P50: at(50), P90: at(90), P99: at(99)}
+}
@@ -0,0 +1,48 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLatencyPercentiles(t *testing.T) {
+	var h latencyHistogram
+	now := time.Now()
+	for i := 0; i < 90; i++ {
+		h.record(now, 40*time.Microsecond)
+	}
+	for i := 0; i < 9; i++ {
+		h.record(now, 3*time.Millisecond)
+	}
+	h.record(now, time.Second)
+
+	// percentiles are the upper bounds of buckets
+	want := LatencyPercentiles{P50: 50 * time.Microsecond, P90: 50 * time.Microsecond, P99: 3200 * time.Microsecond}
+	if got := h.percentiles(now); got != want {
+		t.Fatalf("want %+v, got %+v", want, got)
+	}
+}
+
+func TestLatencyWindow(t *testing.T) {
+	var h latencyHistogram
+	now := time.Now()
+	h.record(now, 40*time.Microsecond)
+
+	// the previous window still counts
+	if got := h.percentiles(now.Add(latencyWindow + 30*time.Second)); got.P50 != 50*time.Microsecond {
+		t.Fatalf("want the previous window kept, got %+v", got)
+	}
+	if got := h.percentiles(now.Add(3*latencyWindow + 20*time.Second)); got != (LatencyPercentiles{}) {
+		t.Fatalf("want old latencies dropped, got %+v", got)
+	}
+}
+
+func TestStatsGetLatency(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	p.Put(c)
+	if s := p.Stats(); s.GetLatency.P99 == 0 {
+		t.Fatalf("want the Get latency recorded, got %+v", s.GetLatency)
+	}
+}
@@ -0,0 +1,53 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// WithLeaseTimeout reclaim connections checked out longer than d, guarding
+// against callers forgetting to give them back. A reclaimed connection is
+// closed, so an rpc still in flight on it will fail, and giving it back
+// later return ERROR_NOT_CHECKED_OUT.
+func WithLeaseTimeout(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.leaseTimeout = d
+	}
+}
+
+// leaseChecker reclaim overdue leases until the pool released
+func (p *GRpcClientPool) leaseChecker() {
+	t := time.NewTicker(p.leaseTimeout / 2)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.reclaimLeases()
+		}
+	}
+}
+
+// reclaimLeases close connections checked out longer than leaseTimeout
+func (p *GRpcClientPool) reclaimLeases() {
+	p.Lock()
+	reclaimed := false
+	now := time.Now()
+	for c := range p.out {
+		if held := now.Sub(c.checkoutTime); held >= p.leaseTimeout {
+			p.logClient(c, "grpc_pool: reclaim client checked out for %v", held)
+			delete(p.out, c)
+			p.retire(c)
+			reclaimed = true
+		}
+	}
+	all := reclaimed && len(p.out) == 0
+	p.Unlock()
+
+	// as unlockReturned, but in a goroutine as MapPool may Release p, which
+	// wait for this one
+	if all && p.onAllReturned != nil {
+		go p.onAllReturned()
+	}
+}
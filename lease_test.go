@@ -0,0 +1,40 @@
+package grpc_pool
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLeaseOverdue(t *testing.T) {
+	var logs logBuffer
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithLeaseTimeout(30*time.Millisecond), WithLogger(&logs))
+
+	c, _ := p.Get()
+	waitUntil(t, func() bool { return p.Stats().Count == 0 })
+	if s := p.Stats(); s.InUse != 0 {
+		t.Fatalf("want the overdue lease reclaimed, got %+v", s)
+	}
+	if !strings.Contains(logs.String(), "reclaim") {
+		t.Fatalf("want the reclaim logged, got %q", logs.String())
+	}
+
+	if err := p.Put(c); err != ERROR_NOT_CHECKED_OUT {
+		t.Fatalf("want ERROR_NOT_CHECKED_OUT, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 || s.Idle != 0 {
+		t.Fatalf("want the reclaimed client not pooled, got %+v", s)
+	}
+}
+
+func TestLeaseInTime(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithLeaseTimeout(time.Minute))
+
+	c, _ := p.Get()
+	if err := p.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Idle != 1 {
+		t.Fatalf("want the client pooled, got %+v", s)
+	}
+}
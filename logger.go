@@ -0,0 +1,65 @@
+package grpc_pool
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Logger is used by the pool to report events, *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// FieldLogger is a Logger taking structured fields, e.g. an adapter of
+// logrus. Events about a connection are logged with its id, address and tags
+// as fields, while a plain Logger gets them appended as key=value pairs.
+type FieldLogger interface {
+	Logger
+	WithFields(fields map[string]interface{}) Logger
+}
+
+// WithLogger set the logger of the pool, nothing is logged by default
+func WithLogger(l Logger) Option {
+	return func(p *GRpcClientPool) {
+		p.logger = l
+	}
+}
+
+// logf log with the pool logger if any
+func (p *GRpcClientPool) logf(format string, v ...interface{}) {
+	if p.logger != nil {
+		p.logger.Printf(format, v...)
+	}
+}
+
+// logClient log an event about c with its fields
+func (p *GRpcClientPool) logClient(c *IdleClient, format string, v ...interface{}) {
+	if p.logger == nil {
+		return
+	}
+
+	fields := make(map[string]interface{})
+	for k, v := range c.Tags() {
+		fields[k] = v
+	}
+	fields["id"], fields["addr"] = c.ID(), p.addr
+
+	if fl, ok := p.logger.(FieldLogger); ok {
+		fl.WithFields(fields).Printf(format, v...)
+		return
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(format, v...))
+	for _, k := range keys {
+		fmt.Fprintf(&b, " %v=%v", k, fields[k])
+	}
+	p.logf("%s", b.String())
+}
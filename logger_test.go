@@ -0,0 +1,55 @@
+package grpc_pool
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fieldLogger is a FieldLogger keeping the fields last given
+type fieldLogger struct {
+	sync.Mutex
+	fields map[string]interface{}
+}
+
+func (l *fieldLogger) Printf(format string, v ...interface{}) {}
+
+func (l *fieldLogger) WithFields(fields map[string]interface{}) Logger {
+	l.Lock()
+	defer l.Unlock()
+
+	l.fields = fields
+	return l
+}
+
+func TestLogClientFields(t *testing.T) {
+	var logs logBuffer
+	p := newTestPool(t, newTestServer(t), 2, time.Minute, WithLogger(&logs), WithLeaseTimeout(20*time.Millisecond))
+
+	c, _ := p.Get()
+	c.SetTag("zone", "z1")
+	waitUntil(t, func() bool { return p.Stats().Count == 0 })
+
+	line := logs.String()
+	for _, kv := range []string{"zone=z1", "id=" + c.ID(), "addr=bufnet"} {
+		if !strings.Contains(line, kv) {
+			t.Fatalf("want %v in the log, got %q", kv, line)
+		}
+	}
+}
+
+func TestLogClientFieldLogger(t *testing.T) {
+	var logs fieldLogger
+	p := newTestPool(t, newTestServer(t), 2, time.Minute, WithLogger(&logs), WithLeaseTimeout(20*time.Millisecond))
+
+	c, _ := p.Get()
+	c.SetTag("zone", "z2")
+	waitUntil(t, func() bool { return p.Stats().Count == 0 })
+
+	logs.Lock()
+	defer logs.Unlock()
+	if logs.fields["zone"] != "z2" || logs.fields["id"] != c.ID() || logs.fields["addr"] != "bufnet" {
+		t.Fatalf("want the client fields, got %v", logs.fields)
+	}
+}
@@ -3,16 +3,22 @@ package grpc_pool
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"google.golang.org/grpc"
 )
 
 type MapPool struct {
 	// Multiple pools
-	pools map[string]*GRpcClientPool
+	pools map[poolKey]*GRpcClientPool
 
 	// Dial function, use to create new conn
 	dialF DialFunc
+	// Dial functions for specified addresses, override dialF
+	dialFs map[string]DialFunc
 
 	// Max size of pool
 	maxCount int
@@ -20,61 +26,325 @@ type MapPool struct {
 	// Idle duration, client will be remove after idleTimeout from last used time
 	idleTimeout time.Duration
 
+	// Release order of pools, higher first, 0 if not set
+	priorities map[string]int
+	// Addresses marked draining, see MarkDraining
+	draining map[string]struct{}
+
+	// Options of every pool created
+	poolOpts []Option
+	// Max pools pinged at once by PingAll, 0 means default
+	pingConcurrency int
+
+	// Max number of pools, LRU ones are released beyond it, 0 means no
+	// limit. used is the sequence of the last GetPool of each pool.
+	maxPools int
+	used     map[poolKey]*atomic.Uint64
+	useSeq   atomic.Uint64
+
 	sync.RWMutex
 }
 
-func NewMapPool(dial DialFunc, maxCount int, idleTimeout time.Duration) *MapPool {
-	return &MapPool{
-		pools:       make(map[string]*GRpcClientPool),
+// poolKey identify a pool in MapPool, pools of the same address for
+// different credentials never share connections
+type poolKey struct {
+	addr string
+	// Fingerprint of credentials given by GetPoolFor, "" by GetPool
+	cred string
+}
+
+func (k poolKey) String() string {
+	if k.cred == "" {
+		return k.addr
+	}
+	return fmt.Sprintf("%v(%v)", k.addr, k.cred)
+}
+
+// MapOption configure optional behaviors of MapPool
+type MapOption func(*MapPool)
+
+func NewMapPool(dial DialFunc, maxCount int, idleTimeout time.Duration, opts ...MapOption) *MapPool {
+	mp := &MapPool{
+		pools:       make(map[poolKey]*GRpcClientPool),
 		dialF:       dial,
 		maxCount:    maxCount,
 		idleTimeout: idleTimeout,
+		priorities:  make(map[string]int),
+		dialFs:      make(map[string]DialFunc),
+		used:        make(map[poolKey]*atomic.Uint64),
+		draining:    make(map[string]struct{}),
 	}
+
+	for _, opt := range opts {
+		opt(mp)
+	}
+
+	return mp
 }
 
-func (mp *MapPool) getPool(addr string) (*GRpcClientPool, error) {
+func (mp *MapPool) getPool(key poolKey) (*GRpcClientPool, error) {
 	mp.RLock()
 	defer mp.RUnlock()
 
-	p, ok := mp.pools[addr]
+	p, ok := mp.pools[key]
 	if !ok {
-		return nil, errors.New(fmt.Sprintf("GRpcClientPool[%v] not exist", addr))
+		return nil, errors.New(fmt.Sprintf("GRpcClientPool[%v] not exist", key))
 	}
 
 	return p, nil
 }
 
+// GetPoolExists return the pool for addr if it exists, never creating one
+func (mp *MapPool) GetPoolExists(addr string) (*GRpcClientPool, bool) {
+	p, err := mp.getPool(poolKey{addr: addr})
+	return p, err == nil
+}
+
 func (mp *MapPool) GetPool(addr string) *GRpcClientPool {
-	p, err := mp.getPool(addr)
+	return mp.GetPoolFor(addr, "")
+}
+
+// GetPoolFor is like GetPool, but the pool is for the credentials
+// fingerprinted by credKey as well as addr, so callers of different
+// connection-level credentials never share connections. Per-address
+// settings, e.g. SetDialFunc, apply to pools of all credentials of addr.
+func (mp *MapPool) GetPoolFor(addr, credKey string) *GRpcClientPool {
+	key := poolKey{addr: addr, cred: credKey}
+	p, err := mp.getPool(key)
 	if err != nil {
-		p = NewGRpcClientPool(addr, mp.dialF, mp.maxCount, mp.idleTimeout)
+		// create the pool without the lock, options like WithEagerDial may
+		// block, the loser of a race is released
+		mp.RLock()
+		dialF, ok := mp.dialFs[addr]
+		if !ok {
+			dialF = mp.dialF
+		}
+		maxCount, idleTimeout, opts := mp.maxCount, mp.idleTimeout, mp.poolOpts
+		mp.RUnlock()
+		newPool := NewGRpcClientPool(addr, dialF, maxCount, idleTimeout, opts...)
+
 		mp.Lock()
-		mp.pools[addr] = p
+		created := false
+		if p = mp.pools[key]; p == nil {
+			p = newPool
+			if _, ok := mp.draining[addr]; ok {
+				p.setDraining(true)
+			}
+			mp.pools[key] = p
+			mp.used[key] = &atomic.Uint64{}
+			if mp.maxPools > 0 {
+				p.onAllReturned = func() { mp.evict(poolKey{}) }
+				created = true
+			}
+		}
+		mp.touch(key)
 		mp.Unlock()
+
+		if p != newPool {
+			newPool.Release()
+		}
+		if created {
+			mp.evict(key)
+		}
+		return p
 	}
+
+	mp.RLock()
+	mp.touch(key)
+	mp.RUnlock()
+
 	return p
 }
 
+// SetDialFunc set the dial function used by the pool of addr instead of the
+// shared one. It takes effect when the pool is created, so call it before
+// the first GetPool of addr.
+func (mp *MapPool) SetDialFunc(addr string, dialF DialFunc) {
+	mp.Lock()
+	mp.dialFs[addr] = dialF
+	mp.Unlock()
+}
+
+// SetDialOptions make the pool of addr dial with DefaultDialOptionsFunc and
+// the given options, e.g. its own credentials. See SetDialFunc for when it
+// takes effect.
+func (mp *MapPool) SetDialOptions(addr string, opts ...grpc.DialOption) {
+	mp.SetDialFunc(addr, func(addr string) (*grpc.ClientConn, error) {
+		return DefaultDialOptionsFunc(addr, opts...)
+	})
+}
+
+// ReleasePool release the pools of addr, for all credentials
 func (mp *MapPool) ReleasePool(addr string) error {
-	p, err := mp.getPool(addr)
+	mp.Lock()
+	var released []*GRpcClientPool
+	for key, p := range mp.pools {
+		if key.addr == addr {
+			delete(mp.pools, key)
+			delete(mp.used, key)
+			released = append(released, p)
+		}
+	}
+	mp.Unlock()
+
+	if len(released) == 0 {
+		return errors.New(fmt.Sprintf("GRpcClientPool[%v] not exist", addr))
+	}
+	for _, p := range released {
+		p.Release()
+	}
+
+	return nil
+}
+
+// PutTo give back c to the pool for addr, which may not be the pool c taken
+// from, e.g. to re-home connections on consistent hashing migrations. The
+// pool must exist, and unless force, addr must be the address c dialed. If
+// the pool is full c is closed and ERROR_MAX_CLIENT_COUNT returned.
+func (mp *MapPool) PutTo(addr string, c *IdleClient, force bool) error {
+	if c == nil {
+		return ERROR_NIL_CLIENT
+	}
+	if c.addr != addr && !force {
+		return fmt.Errorf("Client dialed [%v], can't put to [%v]", c.addr, addr)
+	}
+
+	p, err := mp.getPool(poolKey{addr: addr})
 	if err != nil {
 		return err
 	}
 
-	mp.Lock()
-	delete(mp.pools, addr)
-	mp.Unlock()
+	if c.owner != p {
+		if c.owner != nil && !c.owner.handOff(c) {
+			return ERROR_NOT_CHECKED_OUT
+		}
+		if err := p.adopt(c); err != nil {
+			return err
+		}
+	}
+
+	return p.Put(c)
+}
+
+// handOff stop counting checked out c without closing it, return false if c
+// is not checked out from p
+func (p *GRpcClientPool) handOff(c *IdleClient) bool {
+	p.Lock()
+	defer p.unlockReturned()
+
+	if _, ok := p.out[c]; !ok {
+		return false
+	}
+	delete(p.out, c)
+	p.uncount(c)
+	p.stopWatch(c)
 
-	p.Release()
+	return true
+}
+
+// adopt count c handed off by another pool as checked out from p, c is
+// closed if p is full
+func (p *GRpcClientPool) adopt(c *IdleClient) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.count >= p.maxCount && p.maxCount > 0 {
+		p.closeClient(c)
+		return ERROR_MAX_CLIENT_COUNT
+	}
+	p.addCount(1)
+	p.out[c] = struct{}{}
+	c.owner = p
+	if p.watchState {
+		p.startWatch(c)
+	}
 
 	return nil
 }
 
+// SetPriority set the release order of pool for addr, ReleaseAllPool release
+// pools with higher priority first. Pools have priority 0 by default.
+func (mp *MapPool) SetPriority(addr string, priority int) {
+	mp.Lock()
+	mp.priorities[addr] = priority
+	mp.Unlock()
+}
+
+// ReleaseAllPool release all pools in descending priority order
 func (mp *MapPool) ReleaseAllPool() {
 	mp.Lock()
-	for _, p := range mp.pools {
-		p.Release()
+	keys := make([]poolKey, 0, len(mp.pools))
+	for key := range mp.pools {
+		keys = append(keys, key)
 	}
-	mp.pools = make(map[string]*GRpcClientPool)
+	sort.Slice(keys, func(i, j int) bool {
+		pi, pj := mp.priorities[keys[i].addr], mp.priorities[keys[j].addr]
+		if pi != pj {
+			return pi > pj
+		}
+		if keys[i].addr != keys[j].addr {
+			return keys[i].addr < keys[j].addr
+		}
+		return keys[i].cred < keys[j].cred
+	})
+
+	for _, key := range keys {
+		mp.pools[key].Release()
+	}
+	mp.pools = make(map[poolKey]*GRpcClientPool)
+	mp.used = make(map[poolKey]*atomic.Uint64)
 	mp.Unlock()
 }
+
+// PoolCount return the number of pools
+func (mp *MapPool) PoolCount() int {
+	mp.RLock()
+	defer mp.RUnlock()
+
+	return len(mp.pools)
+}
+
+// Addresses return the sorted addresses of all pools, each once whatever
+// pools for credentials it has
+func (mp *MapPool) Addresses() []string {
+	mp.RLock()
+	seen := make(map[string]struct{}, len(mp.pools))
+	addrs := make([]string, 0, len(mp.pools))
+	for key := range mp.pools {
+		if _, ok := seen[key.addr]; !ok {
+			seen[key.addr] = struct{}{}
+			addrs = append(addrs, key.addr)
+		}
+	}
+	mp.RUnlock()
+
+	sort.Strings(addrs)
+	return addrs
+}
+
+// PoolStats is the Stats of a pool in MapPool
+type PoolStats struct {
+	// Rpc server address of the pool, and credentials given by GetPoolFor
+	Addr    string
+	CredKey string
+
+	Stats
+}
+
+// Stats return the Stats of all pools, sorted by address and credentials
+func (mp *MapPool) Stats() []PoolStats {
+	mp.RLock()
+	stats := make([]PoolStats, 0, len(mp.pools))
+	for key, p := range mp.pools {
+		stats = append(stats, PoolStats{Addr: key.addr, CredKey: key.cred, Stats: p.Stats()})
+	}
+	mp.RUnlock()
+
+	sort.Slice(stats, func(i, j int) bool {
+		if stats[i].Addr != stats[j].Addr {
+			return stats[i].Addr < stats[j].Addr
+		}
+		return stats[i].CredKey < stats[j].CredKey
+	})
+	return stats
+}
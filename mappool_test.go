@@ -0,0 +1,180 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestReleaseAllPoolByPriority(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		closed []string
+	)
+	SetDefaultOptions(WithOnClose(func(c *IdleClient) {
+		mu.Lock()
+		closed = append(closed, c.addr)
+		mu.Unlock()
+	}))
+	defer SetDefaultOptions()
+
+	srv := newTestServer(t)
+	mp := NewMapPool(srv.DialFunc(), 5, time.Minute)
+	mp.SetPriority("frontend", 2)
+	mp.SetPriority("db", -1)
+	for _, addr := range []string{"db", "cache", "frontend"} {
+		c, err := mp.GetPool(addr).Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		mp.GetPool(addr).Put(c)
+	}
+
+	mp.ReleaseAllPool()
+	want := []string{"frontend", "cache", "db"}
+	if len(closed) != len(want) {
+		t.Fatalf("want %v closed, got %v", want, closed)
+	}
+	for i := range want {
+		if closed[i] != want[i] {
+			t.Fatalf("want release order %v, got %v", want, closed)
+		}
+	}
+	if n := mp.PoolCount(); n != 0 {
+		t.Fatalf("want no pool left, got %v", n)
+	}
+}
+
+func TestMapPoolDialPerAddr(t *testing.T) {
+	srv := newTestServer(t)
+	down := errors.New("down")
+	mp := NewMapPool(func(string) (*grpc.ClientConn, error) { return nil, down }, 5, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	mp.SetDialFunc("a", srv.DialFunc())
+	mp.SetDialOptions("passthrough:///b", grpc.WithContextDialer(srv.DialContext))
+
+	for _, addr := range []string{"a", "passthrough:///b"} {
+		c, err := mp.GetPool(addr).Get()
+		if err != nil {
+			t.Fatalf("%v: %v", addr, err)
+		}
+		if err := checkHealth(c); err != nil {
+			t.Fatalf("%v: %v", addr, err)
+		}
+	}
+	if _, err := mp.GetPool("c").Get(); !errors.Is(err, down) {
+		t.Fatalf("want the shared dial func for c, got %v", err)
+	}
+}
+
+func TestGetPoolExists(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 2, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	if p, ok := mp.GetPoolExists("a"); ok || p != nil {
+		t.Fatalf("want no pool on miss, got %v", p)
+	}
+	if n := mp.PoolCount(); n != 0 {
+		t.Fatalf("want no pool created, got %v", n)
+	}
+
+	p := mp.GetPool("a")
+	if p2, ok := mp.GetPoolExists("a"); !ok || p2 != p {
+		t.Fatalf("want the pool of a, got %v", p2)
+	}
+}
+
+func TestPutTo(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 2, time.Minute)
+	defer mp.ReleaseAllPool()
+	pa, pb := mp.GetPool("a"), mp.GetPool("b")
+
+	c, _ := pa.Get()
+	if err := mp.PutTo("b", c, false); err == nil {
+		t.Fatal("want putting to another address rejected")
+	}
+	if err := mp.PutTo("a", c, false); err != nil {
+		t.Fatal(err)
+	}
+	if s := pa.Stats(); s.Idle != 1 {
+		t.Fatalf("want c back to a, got %+v", s)
+	}
+
+	c, _ = pa.Get()
+	if err := mp.PutTo("b", c, true); err != nil {
+		t.Fatal(err)
+	}
+	if sa, sb := pa.Stats(), pb.Stats(); sa.Count != 0 || sb.Count != 1 || sb.Idle != 1 {
+		t.Fatalf("want c re-homed to b, got a %+v and b %+v", sa, sb)
+	}
+	if c2, _ := pb.Get(); c2 != c {
+		t.Fatal("want c served by b")
+	}
+
+	if err := mp.PutTo("nope", c, true); err == nil {
+		t.Fatal("want putting to a missing pool rejected")
+	}
+	pb.Put(c)
+	if err := mp.PutTo("a", c, true); err != ERROR_NOT_CHECKED_OUT {
+		t.Fatalf("want ERROR_NOT_CHECKED_OUT, got %v", err)
+	}
+}
+
+func TestPutToFull(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 1, time.Minute)
+	defer mp.ReleaseAllPool()
+	pa, pb := mp.GetPool("a"), mp.GetPool("b")
+
+	pb.Get()
+	c, _ := pa.Get()
+	if err := mp.PutTo("b", c, true); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+	if s := pa.Stats(); s.Count != 0 {
+		t.Fatalf("want c handed off from a, got %+v", s)
+	}
+}
+
+func TestGetPoolFor(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	a := mp.GetPoolFor("bufnet", "tenant-a")
+	b := mp.GetPoolFor("bufnet", "tenant-b")
+	if a == b || mp.GetPoolFor("bufnet", "tenant-a") != a || mp.GetPool("bufnet") == a {
+		t.Fatal("want a pool for each credentials")
+	}
+
+	c, _ := a.Get()
+	a.Put(c)
+	d, _ := b.Get()
+	if d == c {
+		t.Fatal("want connections not shared across credentials")
+	}
+	b.Put(d)
+
+	if n := len(mp.Addresses()); n != 1 || mp.PoolCount() != 3 {
+		t.Fatalf("want 3 pools of 1 address, got %v of %v", mp.PoolCount(), n)
+	}
+	stats := mp.Stats()
+	if len(stats) != 3 || stats[1].CredKey != "tenant-a" || stats[1].Idle != 1 {
+		t.Fatalf("want stats of each pool, got %+v", stats)
+	}
+	if r := mp.PingAll(context.Background()); r["bufnet"].Healthy != 3 {
+		t.Fatalf("want pools of all credentials pinged, got %+v", r)
+	}
+
+	// per address, for all credentials
+	mp.MarkDraining("bufnet")
+	if !isClosed(a, c) || !isClosed(b, d) {
+		t.Fatal("want pools of all credentials drained")
+	}
+	if err := mp.ReleasePool("bufnet"); err != nil || mp.PoolCount() != 0 {
+		t.Fatalf("want pools of all credentials released, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package grpc_pool
+
+// WithMaxPools keep at most n pools, creating a pool beyond it release the
+// least recently got pools having no connection checked out. If all are
+// busy the limit is exceeded for a while, and pools are released once their
+// connections given back.
+func WithMaxPools(n int) MapOption {
+	return func(mp *MapPool) {
+		mp.maxPools = n
+	}
+}
+
+// touch mark the pool for key used, mp MUST be locked or read locked
+func (mp *MapPool) touch(key poolKey) {
+	if u := mp.used[key]; u != nil {
+		u.Store(mp.useSeq.Add(1))
+	}
+}
+
+// evict release least recently used idle pools but keep until no more than
+// maxPools
+func (mp *MapPool) evict(keep poolKey) {
+	// called on every Put leaving nothing checked out, don't take the write
+	// lock unless over
+	mp.RLock()
+	over := len(mp.pools) > mp.maxPools
+	mp.RUnlock()
+	if !over {
+		return
+	}
+
+	mp.Lock()
+	var released []*GRpcClientPool
+	for len(mp.pools) > mp.maxPools {
+		var (
+			lru  poolKey
+			seq  uint64
+			p    *GRpcClientPool
+			busy = true
+		)
+		for key, pool := range mp.pools {
+			if key == keep {
+				continue
+			}
+			if s := mp.used[key].Load(); (busy || s < seq) && !pool.inUse() {
+				lru, seq, p, busy = key, s, pool, false
+			}
+		}
+		if busy {
+			break
+		}
+
+		delete(mp.pools, lru)
+		delete(mp.used, lru)
+		released = append(released, p)
+	}
+	mp.Unlock()
+
+	for _, p := range released {
+		p.Release()
+	}
+}
+
+// inUse report whether p has connections checked out, cheaper than Stats for
+// scanning many pools
+func (p *GRpcClientPool) inUse() bool {
+	p.Lock()
+	defer p.Unlock()
+
+	return len(p.out) > 0
+}
@@ -0,0 +1,111 @@
+package grpc_pool
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMaxPoolsEvictLRU(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute, WithMaxPools(2))
+	defer mp.ReleaseAllPool()
+
+	mp.GetPool("a")
+	b := mp.GetPool("b")
+	mp.GetPool("a")
+	mp.GetPool("c")
+	if got := mp.Addresses(); !reflect.DeepEqual(got, []string{"a", "c"}) {
+		t.Fatalf("want b evicted as least recently used, got %v", got)
+	}
+	if _, err := b.Get(); err != ERROR_POOL_CLOSED {
+		t.Fatalf("want the evicted pool released, got %v", err)
+	}
+}
+
+func TestMaxPoolsKeepBusy(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute, WithMaxPools(2))
+	defer mp.ReleaseAllPool()
+
+	pa, pc := mp.GetPool("a"), mp.GetPool("c")
+	ca, _ := pa.Get()
+	cc, _ := pc.Get()
+	mp.GetPool("d")
+	if n := mp.PoolCount(); n != 3 {
+		t.Fatalf("want busy pools kept over the limit, got %v", mp.Addresses())
+	}
+
+	pc.Put(cc)
+	if got := mp.Addresses(); !reflect.DeepEqual(got, []string{"a", "d"}) {
+		t.Fatalf("want c evicted once given back, got %v", got)
+	}
+	pa.Put(ca)
+	if n := mp.PoolCount(); n != 2 {
+		t.Fatalf("want 2 pools, got %v", mp.Addresses())
+	}
+}
+
+func TestMaxPoolsConcurrent(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 2, time.Minute, WithMaxPools(3))
+	defer mp.ReleaseAllPool()
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 50; i++ {
+				p := mp.GetPool(string(rune('a' + (g+i)%6)))
+				if c, err := p.Get(); err == nil {
+					p.Put(c)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if n := mp.PoolCount(); n > 3 {
+		t.Fatalf("want at most 3 pools once all given back, got %v", mp.Addresses())
+	}
+}
+
+func TestMaxPoolsEvictReclaimed(t *testing.T) {
+	SetDefaultOptions(WithLeaseTimeout(20 * time.Millisecond))
+	defer SetDefaultOptions()
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute, WithMaxPools(1))
+	defer mp.ReleaseAllPool()
+
+	pa := mp.GetPool("a")
+	pa.Get()
+	mp.GetPool("b")
+	if n := mp.PoolCount(); n != 2 {
+		t.Fatalf("want the busy pool kept, got %v", mp.Addresses())
+	}
+
+	// evictable once the lease reclaimed
+	waitUntil(t, func() bool { return mp.PoolCount() == 1 })
+	if got := mp.Addresses(); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Fatalf("want a evicted, got %v", got)
+	}
+}
+
+func TestMaxPoolsEvictHandedOff(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute, WithMaxPools(2))
+	defer mp.ReleaseAllPool()
+
+	pa, pb := mp.GetPool("a"), mp.GetPool("b")
+	c, _ := pa.Get()
+	pb.Get()
+	mp.GetPool("c")
+	if n := mp.PoolCount(); n != 3 {
+		t.Fatalf("want busy pools kept over the limit, got %v", mp.Addresses())
+	}
+
+	// a has nothing checked out once c handed off to the pool of b
+	if err := mp.PutTo("b", c, true); err != nil {
+		t.Fatal(err)
+	}
+	if got := mp.Addresses(); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Fatalf("want a evicted, got %v", got)
+	}
+}
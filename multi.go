@@ -0,0 +1,32 @@
+package grpc_pool
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// NewGRpcClientPoolMulti create a pool fronting several equivalent replicas,
+// new connections dial addrs in round robin. The address of the pool is
+// addrs joined by comma. An error is returned if addrs is empty or any
+// option is invalid.
+func NewGRpcClientPoolMulti(addrs []string, dialF DialFunc, maxCount int, idleTimeout time.Duration, opts ...Option) (*GRpcClientPool, error) {
+	if len(addrs) == 0 {
+		return nil, errors.New("No address given")
+	}
+
+	addrs = append([]string(nil), addrs...)
+	setAddrs := func(p *GRpcClientPool) {
+		p.addrs = addrs
+	}
+
+	return NewGRpcClientPoolE(strings.Join(addrs, ","), dialF, maxCount, idleTimeout, append([]Option{setAddrs}, opts...)...)
+}
+
+// nextAddr return the address a new conn should dial
+func (p *GRpcClientPool) nextAddr() string {
+	if len(p.addrs) == 0 {
+		return p.addr
+	}
+	return p.addrs[(p.addrSeq.Add(1)-1)%uint64(len(p.addrs))]
+}
@@ -0,0 +1,46 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMultiRoundRobin(t *testing.T) {
+	addrs := []string{"a:1", "b:2", "c:3"}
+	p, err := NewGRpcClientPoolMulti(addrs, newTestServer(t).DialFunc(), 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	for i := 0; i < 6; i++ {
+		c, _ := p.Get()
+		if target := c.GetConn().Target(); target != "passthrough:///"+addrs[i%3] {
+			t.Fatalf("want dial %v to %v, got %v", i, addrs[i%3], target)
+		}
+		if err := checkHealth(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := p.Stats()
+	for _, addr := range addrs {
+		if s.Addrs[addr] != 2 {
+			t.Fatalf("want 2 connections to each address, got %v", s.Addrs)
+		}
+	}
+}
+
+func TestMultiNoAddrs(t *testing.T) {
+	if _, err := NewGRpcClientPoolMulti(nil, newTestServer(t).DialFunc(), 0, 0); err == nil {
+		t.Fatal("want no address rejected")
+	}
+}
+
+func TestSingleNoAddrsStats(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, 0)
+	p.Get()
+	if s := p.Stats(); s.Addrs != nil {
+		t.Fatalf("want no Addrs for a single address pool, got %v", s.Addrs)
+	}
+}
@@ -0,0 +1,58 @@
+package grpc_pool
+
+import (
+	"sync"
+	"time"
+)
+
+// WithNegativeCacheTTL remember a target failed to dial for d, dials to it
+// fail fast with ERROR_DIAL_SUPPRESSED in a DialError until then, after that
+// one dial is let through to probe it. Targets are those resolved, see
+// WithAddrResolver, so a pool with other healthy targets keeps dialing them.
+func WithNegativeCacheTTL(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if d > 0 {
+			p.negCache = &negativeCache{ttl: d, until: make(map[string]time.Time)}
+		}
+	}
+}
+
+// negativeCache hold targets failed to dial recently, it has its own lock as
+// dials run without the pool locked
+type negativeCache struct {
+	ttl time.Duration
+
+	sync.Mutex
+	// Time dials to each target allowed again
+	until map[string]time.Time
+}
+
+// allow report whether target can be dialed now. Once the ttl passed it
+// return true for one probe, others keep failing fast until it's done.
+func (nc *negativeCache) allow(target string, now time.Time) bool {
+	nc.Lock()
+	defer nc.Unlock()
+
+	until, ok := nc.until[target]
+	if !ok {
+		return true
+	}
+	if now.Before(until) {
+		return false
+	}
+	nc.until[target] = now.Add(nc.ttl)
+
+	return true
+}
+
+// done record the result of a dial to target
+func (nc *negativeCache) done(target string, failed bool, now time.Time) {
+	nc.Lock()
+	defer nc.Unlock()
+
+	if failed {
+		nc.until[target] = now.Add(nc.ttl)
+	} else {
+		delete(nc.until, target)
+	}
+}
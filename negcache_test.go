@@ -0,0 +1,52 @@
+package grpc_pool
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestNegativeCache(t *testing.T) {
+	var dials atomic.Int32
+	var down atomic.Bool
+	down.Store(true)
+	dialF := newTestServer(t).DialFunc()
+	p, _ := NewGRpcClientPoolE("bufnet", func(addr string) (*grpc.ClientConn, error) {
+		dials.Add(1)
+		if down.Load() {
+			return nil, errors.New("down")
+		}
+		return dialF(addr)
+	}, 5, time.Minute, WithNegativeCacheTTL(40*time.Millisecond))
+	defer p.Release()
+
+	// failed fast in the ttl
+	for i := 0; i < 5; i++ {
+		if _, err := p.Get(); !IsDialError(err) {
+			t.Fatalf("want a DialError, got %v", err)
+		}
+	}
+	if _, err := p.Get(); !errors.Is(err, ERROR_DIAL_SUPPRESSED) || dials.Load() != 1 {
+		t.Fatalf("want ERROR_DIAL_SUPPRESSED after 1 dial, got %v after %v", err, dials.Load())
+	}
+
+	// one probe after the ttl, failed again
+	time.Sleep(50 * time.Millisecond)
+	p.Get()
+	if _, err := p.Get(); !errors.Is(err, ERROR_DIAL_SUPPRESSED) || dials.Load() != 2 {
+		t.Fatalf("want suppressed after the probe, got %v after %v dials", err, dials.Load())
+	}
+
+	// probe succeeded, dial as usual
+	time.Sleep(50 * time.Millisecond)
+	down.Store(false)
+	if _, err := p.Get(); err != nil {
+		t.Fatalf("want the probe succeeded, got %v", err)
+	}
+	if _, err := p.Get(); err != nil || dials.Load() != 4 {
+		t.Fatalf("want dials not suppressed, got %v after %v dials", err, dials.Load())
+	}
+}
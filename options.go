@@ -0,0 +1,235 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+)
+
+// Option configure optional behaviors of GRpcClientPool
+type Option func(*GRpcClientPool)
+
+var (
+	defaultOpts   []Option
+	defaultOptsMu sync.RWMutex
+)
+
+// SetDefaultOptions set options applied to every pool created since, e.g.
+// org-wide credentials or logger. They are applied before the options given
+// to the constructor, which override them. Pools created by MapPool apply
+// them before its pool options. Calling it again replace the defaults.
+func SetDefaultOptions(opts ...Option) {
+	defaultOptsMu.Lock()
+	defaultOpts = append([]Option(nil), opts...)
+	defaultOptsMu.Unlock()
+}
+
+// withDefaultOptions return the default options followed by opts
+func withDefaultOptions(opts []Option) []Option {
+	defaultOptsMu.RLock()
+	defer defaultOptsMu.RUnlock()
+
+	if len(defaultOpts) == 0 {
+		return opts
+	}
+	return append(append([]Option(nil), defaultOpts...), opts...)
+}
+
+// WithRetirePolicy set the func used by Do to decide whether an error means
+// the connection is bad and should be retired rather than given back.
+// DefaultRetirePolicy is used if not set.
+func WithRetirePolicy(fn func(err error) bool) Option {
+	return func(p *GRpcClientPool) {
+		if fn != nil {
+			p.retirePolicy = fn
+		}
+	}
+}
+
+// WithPauseBlocks make Get block until Resume while the pool is paused,
+// instead of returning ERROR_POOL_PAUSED
+func WithPauseBlocks() Option {
+	return func(p *GRpcClientPool) {
+		p.pauseBlocks = true
+	}
+}
+
+// WithReviveIdle kick connections in Idle state to reconnect when they are
+// given back or handed out, so the next rpc need not wait for connecting.
+// gRPC collapses unused connections into Idle, and grpc.NewClient creates
+// them Idle.
+func WithReviveIdle() Option {
+	return func(p *GRpcClientPool) {
+		p.reviveIdle = true
+	}
+}
+
+// WithMaxIdle limit the number of idle connections, giving back one to a pool
+// already having n idle ones close the oldest by creation, the one given back
+// or an idle one. 0 means no limit.
+func WithMaxIdle(n int) Option {
+	return func(p *GRpcClientPool) {
+		p.maxIdle = n
+	}
+}
+
+// WithMaxLifetime retire connections older than d, idle ones are closed and
+// checked out ones are closed when given back. 0 means no limit.
+func WithMaxLifetime(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.maxLifetime = d
+	}
+}
+
+// WithServerMaxConnAge mirror MaxConnectionAge of the server, connections are
+// retired a margin before d so rpcs don't fail on its GOAWAY. It applies as
+// WithMaxLifetime, the shorter wins if both set. The margin is d/10 unless
+// set by WithServerConnAgeMargin.
+func WithServerMaxConnAge(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.serverMaxConnAge = d
+	}
+}
+
+// WithServerConnAgeMargin set how early connections are retired before the
+// server max connection age, see WithServerMaxConnAge
+func WithServerConnAgeMargin(m time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.connAgeMargin = m
+	}
+}
+
+// WithMaxIdleReuses retire connections taken from idle pool n times when
+// given back, so connections sat idle are rebalanced. Unlike counting all
+// uses, a connection handed out right after dialed isn't counted. 0 means no
+// limit.
+func WithMaxIdleReuses(n int) Option {
+	return func(p *GRpcClientPool) {
+		p.maxIdleReuses = n
+	}
+}
+
+// WithReaper start a background goroutine removing stale connections every
+// interval, rather than only on Get. It is stopped by Release.
+func WithReaper(interval time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.reapInterval = interval
+	}
+}
+
+// WithStaleScanInterval make Get scan for stale connections at most once per d
+// instead of on every call, saving the scan under high QPS. Stale connections
+// between scans are left to the reaper, see WithReaper, so one may be handed
+// out if there is none.
+func WithStaleScanInterval(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.scanInterval = d
+	}
+}
+
+// WithCapturePeer tag each connection with the remote address of the backend
+// it connected to, which can be read by IdleClient.Peer. The tag is empty
+// until connected since dials are lazy. Note if the resolver returns several
+// backends, e.g. round robin over DNS, the conn has a transport per backend
+// and the tag is the backend connected last. It installs a stats.Handler by
+// dial options, so needs a DialOptionsFunc as WithDialOptions.
+func WithCapturePeer() Option {
+	return func(p *GRpcClientPool) {
+		p.capturePeer = true
+	}
+}
+
+// WithDialRateLimit allow at most n dials in any duration of per, so a dead
+// backend can't cause a dial storm. Get needing to dial beyond it return
+// ERROR_DIAL_RATE_LIMITED.
+func WithDialRateLimit(n int, per time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		if n > 0 && per > 0 {
+			p.dialLimit = &dialLimiter{n: n, per: per}
+		}
+	}
+}
+
+// WithMaxConcurrentDials limit dials in flight to n, so a burst of Get after
+// a backend bounced doesn't flood its accept queue. Get beyond it wait for a
+// dial done or a connection given back, whichever first.
+func WithMaxConcurrentDials(n int) Option {
+	return func(p *GRpcClientPool) {
+		if n > 0 {
+			p.dialSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithDialOptionsFunc dial with f and the dial options of the pool, instead
+// of the DialFunc given to NewGRpcClientPool
+func WithDialOptionsFunc(f DialOptionsFunc) Option {
+	return func(p *GRpcClientPool) {
+		if f != nil {
+			p.dialOptsF = f
+		}
+	}
+}
+
+// WithAddrResolver resolve the address of the pool to the target to dial on
+// every dial, e.g. a logical service name to one of its endpoints. An error
+// of fn fail the dial and is returned by Get in a DialError.
+func WithAddrResolver(fn func(ctx context.Context, logical string) (string, error)) Option {
+	return func(p *GRpcClientPool) {
+		p.resolveAddr = fn
+	}
+}
+
+// WithDialOptions add options used to dial new connections. They need a
+// DialOptionsFunc: a nil DialFunc or WithDialOptionsFunc.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(p *GRpcClientPool) {
+		p.dialOpts = append(p.dialOpts, opts...)
+	}
+}
+
+// WithAuthority set the :authority header of rpcs to authority instead of
+// the address dialed, e.g. for TLS server name or virtual hosting. It's a
+// dial option, so needs a DialOptionsFunc as WithDialOptions.
+func WithAuthority(authority string) Option {
+	return func(p *GRpcClientPool) {
+		if authority == "" {
+			p.invalid(errors.New("Authority is empty"))
+			return
+		}
+		p.dialOpts = append(p.dialOpts, grpc.WithAuthority(authority))
+	}
+}
+
+// WithDefaultCompressor make rpcs on pooled connections compressed by the
+// compressor registered as name, e.g. "gzip" after importing
+// google.golang.org/grpc/encoding/gzip
+func WithDefaultCompressor(name string) Option {
+	return func(p *GRpcClientPool) {
+		if encoding.GetCompressor(name) == nil {
+			p.invalid(fmt.Errorf("Compressor[%v] not registered", name))
+			return
+		}
+		p.dialOpts = append(p.dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(name)))
+	}
+}
+
+// WithMinIdle set the number of idle connections Warmup keeps
+func WithMinIdle(n int) Option {
+	return func(p *GRpcClientPool) {
+		p.minIdle = n
+	}
+}
+
+// WithDialTimeout give up dialing a connection, including calling OnDial,
+// after d. 0 means no limit.
+func WithDialTimeout(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.dialTimeout = d
+	}
+}
@@ -0,0 +1,189 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	_ "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestDefaultCompressorUnregistered(t *testing.T) {
+	if _, err := NewGRpcClientPoolE("bufnet", nil, 1, time.Minute, WithDefaultCompressor("nope")); err == nil {
+		t.Fatal("want an unregistered compressor rejected")
+	}
+}
+
+func TestDefaultCompressorDialOption(t *testing.T) {
+	srv := newTestServer(t)
+	if _, err := NewGRpcClientPoolE("bufnet", srv.DialFunc(), 1, time.Minute, WithDefaultCompressor("gzip")); err != ERROR_DIAL_OPTIONS_IGNORED {
+		t.Fatalf("want ERROR_DIAL_OPTIONS_IGNORED with a DialFunc, got %v", err)
+	}
+
+	var got []grpc.DialOption
+	dialF := srv.DialOptionsFunc()
+	p := newTestPool(t, srv, 1, time.Minute, WithDefaultCompressor("gzip"), WithDialOptionsFunc(func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		got = opts
+		return dialF(addr, opts...)
+	}))
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 {
+		t.Fatalf("want the compressor call option, got %v options", len(got))
+	}
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAddrResolver(t *testing.T) {
+	targets := []string{"a:1", "b:2"}
+	var resolved int
+	resolve := func(ctx context.Context, logical string) (string, error) {
+		if logical != "svc" {
+			return "", errors.New("unknown service " + logical)
+		}
+		if resolved >= len(targets) {
+			return "", errors.New("no endpoint")
+		}
+		resolved++
+		return targets[resolved-1], nil
+	}
+	p, err := NewGRpcClientPoolE("svc", newTestServer(t).DialFunc(), 5, time.Minute, WithAddrResolver(resolve))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	c1, _ := p.Get()
+	c2, _ := p.Get()
+	if t1, t2 := c1.GetConn().Target(), c2.GetConn().Target(); t1 != "passthrough:///a:1" || t2 != "passthrough:///b:2" {
+		t.Fatalf("want successive targets dialed, got %v and %v", t1, t2)
+	}
+	if err := checkHealth(c1); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Get(); !IsDialError(err) || errors.Unwrap(err).Error() != "no endpoint" {
+		t.Fatalf("want the resolver error, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 2 {
+		t.Fatalf("want the failed resolve uncounted, got %+v", s)
+	}
+}
+
+func TestAuthority(t *testing.T) {
+	authority := make(chan string, 1)
+	srv := testutil.NewServer(nil, grpc.UnknownServiceHandler(func(_ interface{}, ss grpc.ServerStream) error {
+		md, _ := metadata.FromIncomingContext(ss.Context())
+		authority <- md.Get(":authority")[0]
+		return nil
+	}))
+	t.Cleanup(srv.Stop)
+	p := newTestPool(t, srv, 2, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithAuthority("vhost.example"))
+
+	c, _ := p.Get()
+	c.GetConn().Invoke(context.Background(), "/vhost.Service/Method", nil, nil)
+	if got := <-authority; got != "vhost.example" {
+		t.Fatalf("want the authority overridden, got %q", got)
+	}
+}
+
+func TestAuthorityEmpty(t *testing.T) {
+	if _, err := NewGRpcClientPoolE("bufnet", nil, 2, time.Minute, WithAuthority("")); err == nil {
+		t.Fatal("want an empty authority invalid")
+	}
+}
+
+func TestMaxConcurrentDials(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	var cur, peak atomic.Int32
+	slow := func(addr string) (*grpc.ClientConn, error) {
+		n := cur.Add(1)
+		for m := peak.Load(); n > m && !peak.CompareAndSwap(m, n); m = peak.Load() {
+		}
+		time.Sleep(30 * time.Millisecond)
+		cur.Add(-1)
+		return dial(addr)
+	}
+	p, _ := NewGRpcClientPoolE("bufnet", slow, 20, time.Minute, WithMaxConcurrentDials(2))
+	defer p.Release()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 12; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, err := p.GetContext(context.Background())
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+			p.Put(c)
+		}()
+	}
+	wg.Wait()
+
+	if n := peak.Load(); n > 2 {
+		t.Fatalf("want at most 2 dials at once, got %v", n)
+	}
+	if s := p.Stats(); s.Count != s.Idle || s.Count > 12 {
+		t.Fatalf("want all given back, got %+v", s)
+	}
+	if cfg := p.Config(); cfg.MaxConcurrentDials != 2 {
+		t.Fatalf("want the limit in Config, got %+v", cfg)
+	}
+}
+
+func TestMaxConcurrentDialsWaitCtx(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	slow := func(addr string) (*grpc.ClientConn, error) {
+		time.Sleep(200 * time.Millisecond)
+		return dial(addr)
+	}
+	p, _ := NewGRpcClientPoolE("bufnet", slow, 5, time.Minute, WithMaxConcurrentDials(1))
+	defer p.Release()
+
+	go p.Get()
+	waitUntil(t, func() bool {
+		p.Lock()
+		defer p.Unlock()
+		return len(p.dialing) == 1
+	})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetContext(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded waiting for a dial slot, got %v", err)
+	}
+}
+
+func TestSetDefaultOptions(t *testing.T) {
+	srv := newTestServer(t)
+	var logs logBuffer
+	SetDefaultOptions(WithLogger(&logs), WithMaxIdle(3))
+	defer SetDefaultOptions()
+
+	p := newTestPool(t, srv, 5, time.Minute)
+	if p.logger != &logs || p.maxIdle != 3 {
+		t.Fatal("want the defaults applied")
+	}
+	p = newTestPool(t, srv, 5, time.Minute, WithMaxIdle(1))
+	if p.logger != &logs || p.maxIdle != 1 {
+		t.Fatal("want the defaults overridden by options of the pool")
+	}
+
+	SetDefaultOptions()
+	if p = newTestPool(t, srv, 5, time.Minute); p.logger != nil {
+		t.Fatal("want the defaults cleared")
+	}
+}
@@ -0,0 +1,29 @@
+package grpc_pool
+
+// WithOverflow let Get dial up to n connections beyond maxCount when the pool
+// is exhausted, for bursts. They are ephemeral, closed rather than pooled when
+// given back, so the pool stays at maxCount persistent connections. See
+// Stats.Overflow.
+func WithOverflow(n int) Option {
+	return func(p *GRpcClientPool) {
+		p.maxOverflow = n
+	}
+}
+
+// uncount free the place of c in count, p MUST be locked
+func (p *GRpcClientPool) uncount(c *IdleClient) {
+	if c.ephemeral {
+		// not pooled by whichever pool it's given back to
+		c.ephemeral, c.retireOnReturn = false, true
+		p.overflow--
+	}
+	p.addCount(-1)
+}
+
+// uncountDial free the place of a dial failed or abandoned, p MUST be locked
+func (p *GRpcClientPool) uncountDial(call *dialCall) {
+	if call.overflow {
+		p.overflow--
+	}
+	p.addCount(-1)
+}
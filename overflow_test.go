@@ -0,0 +1,47 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestOverflow(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute, WithOverflow(1))
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	c, err := p.Get()
+	if err != nil || !c.ephemeral {
+		t.Fatalf("want an ephemeral client beyond maxCount, got %v", err)
+	}
+	if _, err := p.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT beyond overflow, got %v", err)
+	}
+	if s := p.Stats(); s.Overflow != 1 || s.Count != 3 || s.OverflowDials != 1 {
+		t.Fatalf("want the overflow tracked, got %+v", s)
+	}
+
+	p.Put(a)
+	if s := p.Stats(); s.Idle != 1 || s.Count != 3 {
+		t.Fatalf("want a pooled, got %+v", s)
+	}
+	// closed rather than pooled
+	p.Put(c)
+	if s := p.Stats(); !isClosed(p, c) || s.Overflow != 0 || s.Count != 2 {
+		t.Fatalf("want the ephemeral client closed, got %+v", s)
+	}
+
+	// the primary first
+	d, _ := p.Get()
+	e, err := p.Get()
+	if d != a || err != nil || !e.ephemeral {
+		t.Fatalf("want a then an ephemeral client, got %v", err)
+	}
+	p.DelErrorClient(e)
+	p.Put(b)
+	p.Put(d)
+	if s := p.Stats(); s.Overflow != 0 || s.Count != 2 || s.Idle != 2 {
+		t.Fatalf("want the primary pooled only, got %+v", s)
+	}
+	checkAccounting(t, p)
+}
@@ -0,0 +1,120 @@
+package grpc_pool
+
+import (
+	"context"
+	"sync"
+)
+
+// Default concurrency of MapPool.PingAll
+const defaultPingConcurrency = 8
+
+// PingResult is the result of pinging a pool
+type PingResult struct {
+	// Connections passed and connections removed as failed
+	Healthy int
+	Removed int
+
+	// Last failure, nil if none
+	Err error
+}
+
+// Ping check every idle connection, or one got by GetContext if none is
+// idle, by the health check if set or else by connecting it. Failed ones
+// are removed. The backend can be considered healthy if Healthy > 0.
+func (p *GRpcClientPool) Ping(ctx context.Context) PingResult {
+	p.Lock()
+	idle := p.idleClients()
+	p.Unlock()
+
+	var r PingResult
+	if len(idle) == 0 {
+		c, err := p.GetContext(ctx)
+		if err != nil {
+			r.Err = err
+			return r
+		}
+		if r.Err = p.ping(ctx, c); r.Err != nil {
+			p.DelErrorClient(c)
+			r.Removed++
+		} else {
+			p.Put(c)
+			r.Healthy++
+		}
+		return r
+	}
+
+	for _, c := range idle {
+		if err := p.ping(ctx, c); err != nil {
+			p.Evict(c)
+			r.Removed++
+			r.Err = err
+		} else {
+			r.Healthy++
+		}
+	}
+
+	return r
+}
+
+// ping check c by the health check, or wait it connected
+func (p *GRpcClientPool) ping(ctx context.Context, c *IdleClient) error {
+	if p.healthCheck != nil {
+		return p.healthCheck(ctx, c)
+	}
+	return waitReady(ctx, c, true)
+}
+
+// WithPingConcurrency limit pools pinged at once by PingAll to n
+func WithPingConcurrency(n int) MapOption {
+	return func(mp *MapPool) {
+		if n > 0 {
+			mp.pingConcurrency = n
+		}
+	}
+}
+
+// PingAll ping every pool concurrently, see GRpcClientPool.Ping, and return
+// the results by address, summed over pools for credentials of an address
+func (mp *MapPool) PingAll(ctx context.Context) map[string]PingResult {
+	mp.RLock()
+	pools := make(map[poolKey]*GRpcClientPool, len(mp.pools))
+	for key, p := range mp.pools {
+		pools[key] = p
+	}
+	n := mp.pingConcurrency
+	mp.RUnlock()
+
+	if n <= 0 {
+		n = defaultPingConcurrency
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, n)
+		results = make(map[string]PingResult, len(pools))
+	)
+	for key, p := range pools {
+		wg.Add(1)
+		go func(addr string, p *GRpcClientPool) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			r := p.Ping(ctx)
+			<-sem
+
+			mu.Lock()
+			sum := results[addr]
+			sum.Healthy += r.Healthy
+			sum.Removed += r.Removed
+			if r.Err != nil {
+				sum.Err = r.Err
+			}
+			results[addr] = sum
+			mu.Unlock()
+		}(key.addr, p)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,49 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestPingAll(t *testing.T) {
+	srv := newTestServer(t)
+	down := func(context.Context, string) (net.Conn, error) { return nil, errors.New("down") }
+	mp := NewMapPool(srv.DialFunc(), 5, time.Minute, WithPingConcurrency(1))
+	defer mp.ReleaseAllPool()
+	mp.SetDialFunc("dead", srv.DialFunc(grpc.WithContextDialer(down)))
+	mp.GetPool("good")
+	mp.GetPool("dead")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	start := time.Now()
+	res := mp.PingAll(ctx)
+	if r := res["good"]; r.Healthy != 1 || r.Err != nil {
+		t.Fatalf("want good healthy, got %+v", r)
+	}
+	if r := res["dead"]; r.Healthy != 0 || r.Removed != 1 || r.Err == nil {
+		t.Fatalf("want dead removed, got %+v", r)
+	}
+	if d := time.Since(start); d > 3*time.Second {
+		t.Fatalf("want the dead backend failing fast, took %v", d)
+	}
+}
+
+func TestPingIdle(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.PutAll([]*IdleClient{a, b})
+	if r := p.Ping(context.Background()); r.Healthy != 2 || r.Removed != 0 {
+		t.Fatalf("want both idle clients healthy, got %+v", r)
+	}
+	if s := p.Stats(); s.Count != 2 || s.Idle != 2 {
+		t.Fatalf("want the idle clients kept, got %+v", s)
+	}
+}
@@ -0,0 +1,42 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// How long a poisoned connection stays idle before retired
+const poisonGrace = 10 * time.Second
+
+// PutPoisoned give back c known degraded but not dead, e.g. rpcs on it got
+// slow, so it's retired soon without redialing at once: it's picked last
+// from idle pool, and retired when given back after one more use, or after
+// idle for a short grace period.
+func (p *GRpcClientPool) PutPoisoned(c *IdleClient) error {
+	if c == nil {
+		return ERROR_NIL_CLIENT
+	}
+
+	err := p.validate(c)
+
+	p.Lock()
+	defer p.unlockReturned()
+
+	if _, ok := p.out[c]; ok && !c.poisoned() {
+		c.poisonedAt = time.Now()
+		c.poisonUses = c.uses
+		p.poisoning = true
+	}
+
+	return p.put(c, err)
+}
+
+// poisoned report whether c is given back by PutPoisoned
+func (c *IdleClient) poisoned() bool {
+	return !c.poisonedAt.IsZero()
+}
+
+// poisonExpired report whether poisoned c is used once more or idle over the
+// grace period
+func (c *IdleClient) poisonExpired() bool {
+	return c.poisoned() && (c.uses > c.poisonUses || time.Since(c.poisonedAt) >= poisonGrace)
+}
@@ -0,0 +1,49 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPutPoisoned(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.PutPoisoned(a)
+	p.Put(b)
+	for i := 0; i < 3; i++ {
+		c, _ := p.Get()
+		if c != b {
+			t.Fatalf("want the poisoned client picked last, round %v", i)
+		}
+		p.Put(c)
+	}
+
+	// used once more, then retired
+	b, _ = p.Get()
+	c, _ := p.Get()
+	if c != a {
+		t.Fatal("want the poisoned client once others taken")
+	}
+	p.Put(c)
+	if !isClosed(p, a) || p.Stats().Count != 1 {
+		t.Fatalf("want the poisoned client retired, got %+v", p.Stats())
+	}
+}
+
+func TestPutPoisonedGrace(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	b, _ := p.Get()
+	p.PutPoisoned(b)
+	p.Lock()
+	b.poisonedAt = time.Now().Add(-poisonGrace)
+	p.pool.rekey(p.idleDeadline)
+	p.nextExpire = time.Time{}
+	p.Unlock()
+
+	if d, _ := p.Get(); d == b || !isClosed(p, b) {
+		t.Fatal("want the poisoned client retired after the grace")
+	}
+}
@@ -3,59 +3,283 @@ package grpc_pool
 // grpc 连接池
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
 )
 
 var (
-	ERROR_MAX_CLIENT_COUNT = errors.New("Client count reach max count")
-	ERROR_INVALID_CLIENT   = errors.New("Invalid client, maybe closed or not connected")
-	ERROR_NIL_CLIENT       = errors.New("Client is nil")
+	ERROR_MAX_CLIENT_COUNT  = errors.New("Client count reach max count")
+	ERROR_INVALID_CLIENT    = errors.New("Invalid client, maybe closed or not connected")
+	ERROR_NIL_CLIENT        = errors.New("Client is nil")
+	ERROR_WOULD_DIAL        = errors.New("No idle client and no dial in flight")
+	ERROR_POOL_PAUSED       = errors.New("Pool is paused")
+	ERROR_DIAL_RATE_LIMITED = errors.New("Dial rate limit exceeded")
+	ERROR_BUDGET_EXCEEDED   = errors.New("Dial estimated to exceed latency budget")
+	ERROR_NOT_CHECKED_OUT   = errors.New("Client is not checked out from pool")
+	ERROR_POOL_CLOSED       = errors.New("Pool is released")
+	ERROR_GET_TIMEOUT       = errors.New("Get blocked over max duration")
+	ERROR_DIAL_SUPPRESSED   = errors.New("Dial suppressed as failed recently")
+	ERROR_WAIT_CANCELLED    = errors.New("Wait cancelled")
+	ERROR_POOL_DRAINING     = errors.New("Pool is draining")
+	ERROR_OVERLOADED        = errors.New("Pool overloaded, too many Gets waiting")
+	ERROR_FD_EXHAUSTED      = errors.New("File descriptors exhausted, not dialing")
+
+	ERROR_DIAL_OPTIONS_IGNORED = errors.New("Dial options need a DialOptionsFunc, they are ignored by DialFunc")
 )
 
 // FOR EXAMPLE:
-// func Dialfunc(addr string) (*grpc.ClientConn, error) {
-//	return grpc.Dial(addr, grpc.WithInsecure(), grpc.WithTimeout())
-// }
+//
+//	func Dialfunc(addr string) (*grpc.ClientConn, error) {
+//		return grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+//	}
 type DialFunc func(string) (*grpc.ClientConn, error)
 
 func DefaultDialFunc(addr string) (*grpc.ClientConn, error) {
-	return grpc.Dial(addr, grpc.WithInsecure())
+	return DefaultDialOptionsFunc(addr)
+}
+
+// DialOptionsFunc is like DialFunc, but also receive the dial options set on
+// the pool, e.g. by WithDialOptions
+type DialOptionsFunc func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error)
+
+// DefaultDialOptionsFunc create an insecure client unless opts has transport
+// credentials. The client connects lazily, it stays Idle until the first rpc
+// or until the pool kicks it, see WithReviveIdle.
+func DefaultDialOptionsFunc(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())}, opts...)
+	return grpc.NewClient(addr, opts...)
 }
 
 // GRpcClientPool is a pool that manage connections to rpc server.
 // cache and remove idle timeout connection, and keep the conn num
 // not over maxCount.
 type GRpcClientPool struct {
-	// Connections to rpc server
-	pool []*IdleClient
+	// Idle connections to rpc server
+	pool idleStore
 
 	// Dial function, use to create new conn
 	dialF DialFunc
+	// Dial function receiving dialOpts, override dialF if set
+	dialOptsF DialOptionsFunc
+	// Extra options for dialOptsF
+	dialOpts []grpc.DialOption
+	// Max duration of a dial including OnDial, 0 means no limit
+	dialTimeout time.Duration
+	// Resolve addr to the target dialed, nil means dial addr
+	resolveAddr func(ctx context.Context, logical string) (string, error)
+	// Keepalive set by options, added to dialOpts. nil means gRPC default.
+	keepalive *keepalive.ClientParameters
+	// Dial each conn with a stats handler counting its rpcs
+	statsTracking bool
 
 	// Max size of pool
 	maxCount int
+	// Called when count cross highWater of maxCount, see
+	// WithHighWaterCallback
+	highWater      float64
+	onHighWater    func(count, max int)
+	aboveHighWater bool
 	// Valid conn num in pool for now
 	count int
-	// Idle duration, client will be remove after idleTimeout from last used time
+	// Max idle conn num, conns given back beyond it are closed
+	maxIdle int
+	// How Get pick an idle conn
+	idleSelect IdleSelect
+	// Pick idle conns never handed out first
+	preferFresh bool
+	// PutPoisoned ever called, idle conns are checked for poison since
+	poisoning bool
+	// Idle conn num Warmup keeps
+	minIdle int
+	// Conns being dialed by Warmup
+	warming int
+	// Conns dialed when created, see WithEagerDial
+	eagerDial int
+	// Earliest time an idle conn become stale, zero if unknown
+	nextExpire time.Time
+	// Min interval of stale scans on Get, and time of the last one
+	scanInterval time.Duration
+	lastScan     time.Time
+	// Max idle conns since the pool last compacted
+	idlePeak int
+
+	// Latencies of successful Gets, it has its own lock
+	getLatency latencyHistogram
+
+	// Cumulative counters, see Stats
+	dials      int64
+	dialErrors int64
+	timeouts   int64
+	reuses     int64
+	// Rpc results, it has its own atomics
+	rpcResults rpcResults
+	// Idle duration, client will be remove after idleTimeout from last used
+	// time, 0 means no idle timeout
 	idleTimeout time.Duration
+	// Max duration since created, older clients are retired
+	maxLifetime time.Duration
+	// Max connection age of the server and how early to retire before it,
+	// see WithServerMaxConnAge
+	serverMaxConnAge time.Duration
+	connAgeMargin    time.Duration
+	// Max times a conn is taken from idle pool, retired after, 0 means no
+	// limit
+	maxIdleReuses int
+	// Interval of background reaping, 0 means no reaper
+	reapInterval time.Duration
+	// Max duration a conn can be checked out, 0 means no limit
+	leaseTimeout time.Duration
+	// Interval of rpcs keeping idle conns warm, 0 means none
+	keepWarm time.Duration
 
 	// Rpc server address
 	addr string
+	// Replicas dialed in round robin, nil means dial addr only
+	addrs   []string
+	addrSeq atomic.Uint64
+
+	// Dials in flight, Get release the lock while dialing
+	dialing map[*dialCall]struct{}
+	// Limit dial rate, nil means no limit
+	dialLimit *dialLimiter
+	// Targets failed to dial recently, nil means no cache
+	negCache *negativeCache
+	// Semaphore of dials in flight, nil means no limit
+	dialSlots chan struct{}
+	// Max delay of the first redial after an outage, 0 means none
+	reconnectJitter time.Duration
+	outage          bool
+	// Fail Get rather than dial slower than latencyBudget, per recent dial
+	// durations. 0 means no budget.
+	latencyBudget time.Duration
+	dialTimes     *dialHistory
+
+	// Max duration GetWait blocks whatever ctx, 0 means no limit
+	maxGetBlock time.Duration
+	// Get wait briefly for a slot freed rather than fail when full
+	aggressiveDial bool
+	// Gets blocked now, and generation and error of CancelWaiters
+	waiters int
+	waitGen uint64
+	waitErr error
+	// Max Gets waiting before shedding, 0 means no limit
+	maxWaiters int
+
+	// Connections taken by Get and not given back yet
+	out map[*IdleClient]struct{}
+
+	// Decide whether an error returned in Do means the conn is bad
+	retirePolicy func(err error) bool
+	// Semaphore of in-flight Do calls, nil means no limit
+	streams chan struct{}
+	// Max streams GetForStream open on a conn, 0 means default
+	streamsPerConn int
+
+	// Recent lifecycle events, nil if not kept
+	events *eventLog
+	// Max conns dialed beyond maxCount, conns counted of them, and their
+	// dials in total
+	maxOverflow   int
+	overflow      int
+	overflowDials int64
+	// A dial failed as file descriptors exhausted, and none closed nor dial
+	// succeeded since. Time the next probe dial is let through, see fdBlocked.
+	fdExhausted atomic.Bool
+	fdProbeAt   time.Time
+	// Retries of Do allowed, nil means no retry
+	retries *retryBudget
+
+	// Hooks called after dialed, and on idle conns before handed out
+	onDial      HookFunc
+	healthCheck HookFunc
+	// Called before a conn closed
+	onClose func(c *IdleClient)
+	// Consulted before dialing for Get, an error deny the dial
+	quotaHook func(addr string) error
+	// Fail dials for testing, and dials attempted for it
+	dialFault    func(addr string, attempt int) error
+	dialAttempts atomic.Int64
+	// Rpc priming conns dialed by Warmup
+	warmupRPC func(ctx context.Context, cc *grpc.ClientConn) error
+
+	// Reconnect Idle conns rather than discard them
+	reviveIdle bool
+	// Tag conns with their peer after dialed
+	capturePeer bool
+	// Drain conns on GOAWAY or failure, see WithStateWatching
+	watchState bool
+
+	// Get fail or block while paused
+	paused      bool
+	pauseBlocks bool
+
+	// Signaled when the pool state changed, e.g. resumed or a conn
+	// entered the idle pool
+	cond *sync.Cond
+
+	// Set by CloseGracefully, conns are closed as given back
+	closing atomic.Bool
+	// Backend marked draining by MapPool, no dial and conns are closed as
+	// given back till marked active
+	draining bool
+	// Called as conns left decrease while CloseGracefully waiting
+	drainProgress func(remaining int)
+	// Closed to stop the running SlowDrain, and the ticker it waits on,
+	// time.NewTicker unless replaced by tests
+	slowDrainStop chan struct{}
+	newTicker     func(d time.Duration) (<-chan time.Time, func())
+	// Timers ending BoostMaxCount boosts, stopped by Release
+	boostTimers map[*time.Timer]struct{}
+
+	// Cancelled on Release to stop background goroutines and dials in
+	// flight, done is ctx.Done(). Release wait background goroutines exited
+	// by wg.
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   <-chan struct{}
+	wg     sync.WaitGroup
+
+	// First invalid option, see NewGRpcClientPoolE
+	optErr error
+
+	// Called without the lock once no conn is checked out after giving back,
+	// used by MapPool to evict pools
+	onAllReturned func()
+
+	// Report events, nil means no log
+	logger    Logger
+	collector Collector
 
 	sync.Mutex
 }
 
-func NewGRpcClientPool(addr string, dialF DialFunc, maxCount int, idleTimeout time.Duration) *GRpcClientPool {
-	if dialF == nil {
-		dialF = DefaultDialFunc
-	}
+// dialCall is a dial in flight, other goroutines can wait for it done
+type dialCall struct {
+	done chan struct{}
+	err  error
 
-	return &GRpcClientPool{
-		pool: make([]*IdleClient, 0),
+	// Wait before dialing, see WithReconnectJitter
+	delay time.Duration
+	// Dial beyond maxCount, see WithOverflow
+	overflow bool
+}
+
+// NewGRpcClientPool create a pool, dialF can be nil to use DefaultDialOptionsFunc.
+// An idleTimeout of 0 means idle connections never time out. Invalid options
+// are ignored, use NewGRpcClientPoolE to find them out.
+func NewGRpcClientPool(addr string, dialF DialFunc, maxCount int, idleTimeout time.Duration, opts ...Option) *GRpcClientPool {
+	p := &GRpcClientPool{
+		pool: &sliceStore{},
 
 		dialF: dialF,
 
@@ -64,25 +288,168 @@ func NewGRpcClientPool(addr string, dialF DialFunc, maxCount int, idleTimeout ti
 		idleTimeout: idleTimeout,
 
 		addr: addr,
+
+		dialing: make(map[*dialCall]struct{}),
+
+		out: make(map[*IdleClient]struct{}),
+
+		retirePolicy: DefaultRetirePolicy,
+	}
+
+	p.cond = sync.NewCond(&p.Mutex)
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.done = p.ctx.Done()
+
+	if dialF == nil {
+		p.dialOptsF = DefaultDialOptionsFunc
+	}
+
+	for _, opt := range withDefaultOptions(opts) {
+		opt(p)
+	}
+	if opt := p.keepaliveDialOption(); opt != nil {
+		p.dialOpts = append(p.dialOpts, opt)
 	}
+
+	if p.dialOptsF == nil && (len(p.dialOpts) > 0 || p.statsTracking || p.capturePeer) {
+		p.invalid(ERROR_DIAL_OPTIONS_IGNORED)
+	}
+	p.checkKeepWarm()
+
+	if p.reapInterval > 0 {
+		p.background(p.reaper)
+	}
+	if p.leaseTimeout > 0 {
+		p.background(p.leaseChecker)
+	}
+	if p.keepWarm > 0 && (p.warmupRPC != nil || p.healthCheck != nil) {
+		p.background(p.keepWarmer)
+	}
+	if p.eagerDial > 0 && p.optErr == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), p.eagerDialTimeout())
+		if err := p.Start(ctx); err != nil {
+			p.invalid(err)
+		}
+		cancel()
+	}
+
+	return p
 }
 
+// released report whether Release called
+func (p *GRpcClientPool) released() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// background run f in a goroutine that Release wait for, f must return once
+// p.done closed
+func (p *GRpcClientPool) background(f func()) {
+	p.wg.Add(1)
+	go func() {
+		defer p.wg.Done()
+		f()
+	}()
+}
+
+// NewGRpcClientPoolE is like NewGRpcClientPool, but return an error if any
+// option is invalid
+func NewGRpcClientPoolE(addr string, dialF DialFunc, maxCount int, idleTimeout time.Duration, opts ...Option) (*GRpcClientPool, error) {
+	p := NewGRpcClientPool(addr, dialF, maxCount, idleTimeout, opts...)
+	if p.optErr != nil {
+		p.Release()
+		return nil, p.optErr
+	}
+
+	return p, nil
+}
+
+// invalid record the error of an invalid option
+func (p *GRpcClientPool) invalid(err error) {
+	if p.optErr == nil {
+		p.optErr = err
+	}
+}
+
+// Sequence of IdleClient ids
+var clientSeq atomic.Uint64
+
 // IdleClient is the implement of connection of rpc server
 type IdleClient struct {
+	// Unique id assigned when created
+	id uint64
+
 	// Last time be called
 	lastCalledTime time.Time
+	// Time the conn was created
+	createdTime time.Time
+
+	// Times taken by Get
+	uses int
+	// Times taken from idle pool by Get, see WithMaxIdleReuses
+	idleReuses int
+	// Rpc latency estimate in nanoseconds, see PutWithLatency
+	rtt atomic.Int64
+	// Time given back by PutPoisoned and uses then, zero if not poisoned
+	poisonedAt time.Time
+	poisonUses int
+	// Streams open on the conn by GetForStream, 0 if not shared
+	streams int
+
+	// Last time taken by Get
+	checkoutTime time.Time
+	// Index in the idle heap, see WithIdleHeap
+	heapIndex int
+
+	// Close rather than pool it when given back, ephemeral as dialed
+	// beyond maxCount, see WithOverflow
+	retireOnReturn bool
+	ephemeral      bool
+	// Closed already, maybe by the pool while checked out
+	closed bool
+
+	// User or pool defined labels, and labels until given back
+	tags         map[string]string
+	checkoutTags map[string]string
+	tagsMu       sync.RWMutex
+
+	// Rpc counters, nil if stats tracking not enabled
+	rpcStats *rpcStats
+
+	// Pool counting the conn, and the address it dialed
+	owner *GRpcClientPool
+	addr  string
+	// Stop the state watcher of owner, see WithStateWatching
+	unwatch func()
 
 	// Socket conn
 	conn *grpc.ClientConn
 }
 
+// ID return the unique id of c, for logging and matching c where the pointer
+// is not convenient
+func (c *IdleClient) ID() string {
+	return strconv.FormatUint(c.id, 10)
+}
+
 func (c *IdleClient) GetConn() *grpc.ClientConn {
 	return c.conn
 }
 
+// CreatedAt return the time c was dialed
+func (c *IdleClient) CreatedAt() time.Time {
+	return c.createdTime
+}
+
 func newIdleClient(conn *grpc.ClientConn) *IdleClient {
 	return &IdleClient{
-		conn: conn,
+		id:          clientSeq.Add(1),
+		createdTime: time.Now(),
+		conn:        conn,
 	}
 }
 
@@ -98,80 +465,784 @@ func (c *IdleClient) updateLastCalledTime() {
 	c.lastCalledTime = time.Now()
 }
 
+// checkValid reject closed and failing conns. Idle is valid since clients
+// created by grpc.NewClient start Idle and connect on the first rpc, and
+// gRPC moves unused conns back to Idle.
 func (c *IdleClient) checkValid() error {
-	state := c.conn.GetState()
-	if int(state) != 2 {
-		return ERROR_INVALID_CLIENT
+	switch c.conn.GetState() {
+	case connectivity.Idle, connectivity.Connecting, connectivity.Ready:
+		return nil
 	}
 
-	return nil
+	return ERROR_INVALID_CLIENT
+}
+
+// revive kick an Idle conn to reconnect without waiting for it
+func (c *IdleClient) revive() {
+	if c.conn.GetState() == connectivity.Idle {
+		c.conn.Connect()
+	}
 }
 
-func (c *IdleClient) close() {
-	c.conn.Close()
+func (c *IdleClient) close() error {
+	c.closed = true
+	if c.rpcStats != nil {
+		c.rpcStats.reset()
+	}
+	return c.conn.Close()
 }
 
 // Get return a valid connection of rpc server, or an error
-func (p *GRpcClientPool) Get() (c *IdleClient, err error) {
+func (p *GRpcClientPool) Get() (*IdleClient, error) {
+	return p.GetContext(context.Background())
+}
+
+// GetContext is like Get, ctx is used to wait and dial, and passed to hooks
+// like OnDial and HealthCheck
+func (p *GRpcClientPool) GetContext(ctx context.Context) (*IdleClient, error) {
+	start := time.Now()
+	c, err := p.getContext(ctx)
+	if err == nil {
+		now := time.Now()
+		p.getLatency.record(now, now.Sub(start))
+	}
+
+	return c, err
+}
+
+func (p *GRpcClientPool) getContext(ctx context.Context) (*IdleClient, error) {
+	waits := 0
+	for {
+		p.Lock()
+		if err := p.waitResumed(ctx); err != nil {
+			p.Unlock()
+			return nil, err
+		}
+		p.scanStale()
+
+		if c := p.popIdle(); c != nil { // get a conn from pool
+			p.checkout(c)
+			p.Unlock()
+			ok, err := p.borrow(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return c, nil
+			}
+			continue
+		}
+
+		// wait for a dial slot or a conn given back
+		if p.dialSlots != nil && len(p.dialing) >= cap(p.dialSlots) && !p.released() {
+			err := p.waitDialSlot(ctx)
+			p.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// ask the quota hook without the lock, it may be remote
+		if p.quotaHook != nil {
+			p.Unlock()
+			if err := p.quotaHook(p.addr); err != nil {
+				return nil, err
+			}
+			p.Lock()
+		}
+
+		// create new conn
+		call, err := p.reserveDial(ctx)
+		if err == ERROR_MAX_CLIENT_COUNT && p.aggressiveDial && waits < aggressiveDialRetries {
+			waits++
+			err = p.waitRoomBriefly(ctx)
+			p.Unlock()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+		p.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		return p.dial(ctx, call, true)
+	}
+}
+
+// GetFresh always dial a new connection, bypassing idle ones, e.g. for health
+// probes which shouldn't reuse a possibly stale connection. Limits, e.g.
+// maxCount, apply as Get dialing.
+func (p *GRpcClientPool) GetFresh(ctx context.Context, opts ...FreshOption) (*IdleClient, error) {
+	if err := p.askQuota(); err != nil {
+		return nil, err
+	}
+
 	p.Lock()
-	defer p.Unlock()
+	if err := p.waitResumed(ctx); err != nil {
+		p.Unlock()
+		return nil, err
+	}
+	call, err := p.reserveDial(ctx)
+	p.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := p.dial(ctx, call, true)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	for _, opt := range opts {
+		opt(c)
+	}
+	p.Unlock()
+
+	return c, nil
+}
+
+// FreshOption configure a connection got by GetFresh
+type FreshOption func(*IdleClient)
+
+// WithNoPoolReturn close the connection when given back instead of pooling it
+func WithNoPoolReturn() FreshOption {
+	return func(c *IdleClient) {
+		c.retireOnReturn = true
+	}
+}
+
+// waitDialSlot wait once for a dial done or a conn entered the idle pool,
+// p MUST be locked
+func (p *GRpcClientPool) waitDialSlot(ctx context.Context) error {
+	stop := p.wakeOnDone(ctx)
+	defer stop()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := p.shed(); err != nil {
+		return err
+	}
+
+	gen := p.enterWait()
+	defer p.leaveWait()
+
+	p.cond.Wait()
+	if err := p.waitCancelled(gen); err != nil {
+		return err
+	}
+
+	return ctx.Err()
+}
+
+// reserveDial take a slot in count for a new conn if limits allow, p MUST be
+// locked
+func (p *GRpcClientPool) reserveDial(ctx context.Context) (*dialCall, error) {
+	if p.released() || p.closing.Load() {
+		return nil, ERROR_POOL_CLOSED
+	}
+	if p.draining {
+		return nil, ERROR_POOL_DRAINING
+	}
+	if p.fdBlocked() {
+		return nil, ERROR_FD_EXHAUSTED
+	}
+	overflow := false
+	if p.count-p.overflow >= p.maxCount && p.maxCount > 0 {
+		if p.overflow >= p.maxOverflow {
+			return nil, ERROR_MAX_CLIENT_COUNT
+		}
+		overflow = true
+	}
+	if p.overBudget(ctx) {
+		return nil, ERROR_BUDGET_EXCEEDED
+	}
+	if p.dialLimit != nil && !p.dialLimit.allow(time.Now()) {
+		return nil, ERROR_DIAL_RATE_LIMITED
+	}
+	p.addCount(1)
+
+	call := p.startDial()
+	if overflow {
+		call.overflow = true
+		p.overflow++
+	}
+	return call, nil
+}
+
+// borrow run HealthCheck on c taken from idle pool. A bad c is retired and
+// false returned, ctx error is returned after giving back c.
+func (p *GRpcClientPool) borrow(ctx context.Context, c *IdleClient) (bool, error) {
+	if p.healthCheck == nil {
+		return true, nil
+	}
+
+	if p.healthCheck(ctx, c) == nil {
+		return true, nil
+	}
+	if err := ctx.Err(); err != nil {
+		p.Put(c)
+		return false, err
+	}
+
+	p.DelErrorClient(c)
+	return false, nil
+}
+
+// GetNoDial return an idle connection, or join a dial in flight started by
+// another goroutine, but never dial itself. The joined dial's connection goes
+// to the goroutine which started it, so once a joined dial is done GetNoDial
+// look again for an idle connection or another dial to join.
+// ERROR_WOULD_DIAL is returned if there is neither idle connection nor dial in
+// flight to join.
+func (p *GRpcClientPool) GetNoDial(ctx context.Context) (*IdleClient, error) {
+	for {
+		p.Lock()
+		if err := p.waitResumed(ctx); err != nil {
+			p.Unlock()
+			return nil, err
+		}
+		p.scanStale()
 
-	// del stale conns
-	index := 0
-	for _, c := range p.pool {
-		if !c.idleTimeout(p.idleTimeout) {
+		if c := p.popIdle(); c != nil {
+			p.checkout(c)
+			p.Unlock()
+			ok, err := p.borrow(ctx, c)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				return c, nil
+			}
+			continue
+		}
+
+		var call *dialCall
+		for call = range p.dialing {
 			break
-		} else {
-			c.close()
-			if p.count > 0 {
-				p.count--
+		}
+
+		p.Unlock()
+
+		if call == nil {
+			return nil, ERROR_WOULD_DIAL
+		}
+
+		select {
+		case <-call.done:
+			if call.err != nil {
+				return nil, call.err
 			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
 		}
-		index++
 	}
-	p.pool = p.pool[index:]
+}
 
-	if len(p.pool) == 0 { // create new conn
-		if p.count >= p.maxCount && p.maxCount > 0 {
-			return nil, ERROR_MAX_CLIENT_COUNT
+// Pause stop handing out connections until Resume, Get return ERROR_POOL_PAUSED
+// or block if WithPauseBlocks is set. Connections can still be given back.
+func (p *GRpcClientPool) Pause() {
+	p.Lock()
+	p.paused = true
+	p.event(EventPause, "")
+	p.Unlock()
+}
+
+// Resume restore a paused pool and wake up the blocked Get
+func (p *GRpcClientPool) Resume() {
+	p.Lock()
+	p.paused = false
+	p.event(EventResume, "")
+	p.cond.Broadcast()
+	p.Unlock()
+}
+
+// waitResumed return at once if pool not paused, otherwise fail or block until
+// resumed or ctx done, p MUST be locked
+func (p *GRpcClientPool) waitResumed(ctx context.Context) error {
+	if !p.paused {
+		return nil
+	}
+	if !p.pauseBlocks {
+		return ERROR_POOL_PAUSED
+	}
+
+	defer p.wakeOnDone(ctx)()
+
+	gen := p.enterWait()
+	defer p.leaveWait()
+
+	for p.paused {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.cond.Wait()
+		if err := p.waitCancelled(gen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WaitWarm block until the pool has at least n idle connections, e.g. after
+// Warmup done, or ctx done. It fails at once if n is over maxCount, and with
+// ERROR_POOL_CLOSED if the pool is released.
+func (p *GRpcClientPool) WaitWarm(ctx context.Context, n int) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.maxCount > 0 && n > p.maxCount {
+		return fmt.Errorf("WaitWarm n[%v] over maxCount[%v]", n, p.maxCount)
+	}
+
+	defer p.wakeOnDone(ctx)()
+
+	for p.pool.size() < n {
+		if p.released() {
+			return ERROR_POOL_CLOSED
+		}
+		if err := ctx.Err(); err != nil {
+			return err
 		}
+		p.cond.Wait()
+	}
 
-		cc, err := p.dialF(p.addr)
-		if err != nil {
+	return nil
+}
+
+// wakeOnDone wake up goroutines waiting on cond once ctx done, so they can
+// notice it. Call the returned func to stop it.
+func (p *GRpcClientPool) wakeOnDone(ctx context.Context) (stop func() bool) {
+	return context.AfterFunc(ctx, func() {
+		p.Lock()
+		p.cond.Broadcast()
+		p.Unlock()
+	})
+}
+
+// scanStale delStaleClients on Get, at most once per scanInterval if set,
+// p MUST be locked
+func (p *GRpcClientPool) scanStale() {
+	if p.scanInterval > 0 {
+		now := time.Now()
+		if now.Sub(p.lastScan) < p.scanInterval {
+			return
+		}
+		p.lastScan = now
+	}
+	p.delStaleClients()
+}
+
+// delStaleClients close and remove idle timeout clients, and clients
+// outlived their lifetime, p MUST be locked
+func (p *GRpcClientPool) delStaleClients() {
+	if time.Now().Before(p.nextExpire) { // nothing stale
+		return
+	}
+
+	timeouts := p.timeouts
+	defer func() {
+		if n := p.timeouts - timeouts; n > 0 {
+			p.event(EventReap, "%v stale connections", n)
+		}
+	}()
+
+	var expired []*IdleClient
+	expired, p.nextExpire = p.pool.popExpired(time.Now())
+	for _, c := range expired {
+		p.retire(c)
+		p.timeouts++
+	}
+}
+
+// addIdle put c into idle pool, p MUST be locked
+func (p *GRpcClientPool) addIdle(c *IdleClient) {
+	deadline := p.idleDeadline(c)
+	p.pool.push(c, deadline)
+	if p.pool.size() > p.idlePeak {
+		p.idlePeak = p.pool.size()
+	}
+	if p.nextExpire.IsZero() || deadline.Before(p.nextExpire) {
+		p.nextExpire = deadline
+	}
+	p.cond.Broadcast()
+}
+
+// Deadline of idle clients never stale, no idle timeout nor lifetime
+var neverStale = time.Date(9999, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// idleDeadline return the time idle c become stale, by idle timeout,
+// lifetime or poison, p MUST be locked
+func (p *GRpcClientPool) idleDeadline(c *IdleClient) time.Time {
+	t := neverStale
+	if p.idleTimeout > 0 {
+		t = c.lastCalledTime.Add(p.idleTimeout)
+	}
+	if lifetime := p.lifetime(); lifetime > 0 {
+		if lt := c.createdTime.Add(lifetime); lt.Before(t) {
+			t = lt
+		}
+	}
+	if c.poisoned() {
+		if pt := c.poisonedAt.Add(poisonGrace); pt.Before(t) {
+			t = pt
+		}
+	}
+
+	return t
+}
+
+// retire close c and free its place in count, p MUST be locked
+func (p *GRpcClientPool) retire(c *IdleClient) {
+	if c.closed {
+		return
+	}
+	p.event(EventRetire, "id=%v addr=%v", c.id, c.addr)
+	p.closeClient(c)
+	p.uncount(c)
+}
+
+// addCount change count by n, never below 0, p MUST be locked
+func (p *GRpcClientPool) addCount(n int) {
+	p.count += n
+	if p.count < 0 {
+		p.count = 0
+	}
+	if n < 0 {
+		// wake up GetWait waiting for room to dial
+		p.cond.Broadcast()
+		if p.drainProgress != nil {
+			p.drainProgress(len(p.out) + len(p.dialing))
+		}
+	}
+	p.checkHighWater()
+}
+
+// popIdle take an idle client out of pool, see WithIdleSelect, p MUST be locked
+func (p *GRpcClientPool) popIdle() *IdleClient {
+	if p.pool.size() == 0 {
+		return nil
+	}
+
+	c := p.pool.removeAt(p.idleIndex())
+	p.reuses++
+	c.idleReuses++
+
+	if p.reviveIdle {
+		c.revive()
+	}
+	return c
+}
+
+// checkout mark c as taken by caller, p MUST be locked
+func (p *GRpcClientPool) checkout(c *IdleClient) {
+	c.uses++
+	c.checkoutTime = time.Now()
+	p.out[c] = struct{}{}
+}
+
+// startDial register a dial in flight, p MUST be locked
+func (p *GRpcClientPool) startDial() *dialCall {
+	call := &dialCall{done: make(chan struct{}), delay: p.redialDelay()}
+	p.dialing[call] = struct{}{}
+	return call
+}
+
+// dialConn dial addr with the dial function of the pool and opts after the
+// dial options of the pool, giving up when ctx done. A conn dialed after
+// giving up is closed.
+func (p *GRpcClientPool) dialConn(ctx context.Context, addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+	// may be swapped by SetDialFunc
+	p.Lock()
+	dialOptsF, dialFunc := p.dialOptsF, p.dialF
+	p.Unlock()
+
+	dialF := func() (*grpc.ClientConn, error) {
+		if dialOptsF != nil {
+			return dialOptsF(addr, append(p.dialOpts[:len(p.dialOpts):len(p.dialOpts)], opts...)...)
+		}
+		return dialFunc(addr)
+	}
+	if ctx.Done() == nil {
+		return dialF()
+	}
+
+	type result struct {
+		cc  *grpc.ClientConn
+		err error
+	}
+	ch := make(chan result, 1)
+	go func() {
+		cc, err := dialF()
+		ch <- result{cc, err}
+	}()
+
+	select {
+	case r := <-ch:
+		return r.cc, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-ch; r.cc != nil {
+				r.cc.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// newClient dial a new conn and run OnDial on it, p need not be locked
+func (p *GRpcClientPool) newClient(ctx context.Context) (*IdleClient, error) {
+	if p.dialTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.dialTimeout)
+		defer cancel()
+	}
+
+	var (
+		st   *rpcStats
+		pc   *peerCapture
+		opts []grpc.DialOption
+	)
+	if p.statsTracking {
+		st = &rpcStats{results: &p.rpcResults}
+		opts = append(opts, grpc.WithStatsHandler(st))
+	}
+	if p.capturePeer {
+		pc = &peerCapture{}
+		opts = append(opts, grpc.WithStatsHandler(pc))
+	}
+
+	addr := p.nextAddr()
+	target := addr
+	if p.resolveAddr != nil {
+		var err error
+		if target, err = p.resolveAddr(ctx, addr); err != nil {
+			return nil, &DialError{Addr: addr, Err: err}
+		}
+	}
+
+	if p.negCache != nil && !p.negCache.allow(target, time.Now()) {
+		return nil, &DialError{Addr: target, Err: ERROR_DIAL_SUPPRESSED}
+	}
+	var (
+		cc  *grpc.ClientConn
+		err error
+	)
+	if p.dialFault != nil {
+		err = p.dialFault(addr, int(p.dialAttempts.Add(1)))
+	}
+	if err == nil {
+		cc, err = p.dialConn(ctx, target, opts...)
+	}
+	if p.negCache != nil && ctx.Err() == nil {
+		p.negCache.done(target, err != nil, time.Now())
+	}
+	if err != nil {
+		return nil, &DialError{Addr: target, Err: err}
+	}
+
+	c := newIdleClient(cc)
+	c.rpcStats = st
+	c.owner, c.addr = p, addr
+	if pc != nil {
+		pc.attach(c)
+	}
+
+	if p.onDial != nil {
+		if err := p.onDial(ctx, c); err != nil {
+			p.closeClient(c)
 			return nil, err
 		}
-		c = newIdleClient(cc)
-		c.updateLastCalledTime()
+	}
 
-		p.count++
+	return c, nil
+}
+
+// dial create new conn without holding the lock, the caller has
+// already taken a place in count for it. The new client is checked out
+// for the caller, or put into idle pool for warmup.
+func (p *GRpcClientPool) dial(ctx context.Context, call *dialCall, checkout bool) (*IdleClient, error) {
+	// give up dialing on Release
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer context.AfterFunc(p.ctx, cancel)()
+
+	var c *IdleClient
+	err := ctx.Err()
+	if err == nil && call.delay > 0 {
+		t := time.NewTimer(call.delay)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			err = ctx.Err()
+		}
+	}
+	if err == nil && p.dialSlots != nil {
+		select {
+		case p.dialSlots <- struct{}{}:
+			defer func() { <-p.dialSlots }()
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+	start := time.Now()
+	if err == nil {
+		c, err = p.newClient(ctx)
+	}
+	if err == nil && !checkout && p.warmupRPC != nil {
+		if err = p.warmupRPC(ctx, c.GetConn()); err != nil {
+			p.closeClient(c)
+			c = nil
+		}
+	}
 
-	} else { // get a conn from pool
-		c = p.pool[0]
-		p.pool = p.pool[1:]
+	p.Lock()
+	delete(p.dialing, call)
+	if !checkout {
+		p.warming--
+	}
+	if p.dialSlots != nil {
+		// wake up Get waiting for a dial slot
+		p.cond.Broadcast()
+	}
+	if p.released() || p.closing.Load() {
+		// Release left the slot of the dial counted
+		if c != nil {
+			p.closeClient(c)
+			c = nil
+		}
+		err = ERROR_POOL_CLOSED
+		p.uncountDial(call)
+	} else if err != nil {
+		p.uncountDial(call)
+		p.dialErrors++
+		p.markOutage()
+		p.noteDialError(err)
+		p.event(EventDialError, "%v", err)
+	} else {
+		p.dials++
+		p.noteFDFreed()
+		p.event(EventDial, "id=%v addr=%v", c.id, c.addr)
+		if p.dialTimes != nil {
+			p.timeDial(c, start)
+		}
+		c.updateLastCalledTime()
+		if call.overflow {
+			c.ephemeral = true
+			p.overflowDials++
+		}
+		if checkout {
+			p.checkout(c)
+		} else if p.draining {
+			p.retire(c)
+		} else {
+			p.addIdle(c)
+		}
+		if p.watchState {
+			p.startWatch(c)
+		}
 	}
+	p.Unlock()
+
+	call.err = err
+	close(call.done)
 
-	return
+	return c, err
 }
 
-// Put give back connection to pool
+// Put give back connection to pool. Checkout scoped data is reset, i.e. the
+// checkout time and tags set by SetCheckoutTag, while tags set by SetTag, the
+// RTT estimate and use counts persist for the life of the connection. While
+// the pool is closing by CloseGracefully or draining, the connection is
+// closed rather than pooled, counting down the connections CloseGracefully
+// waits for.
 func (p *GRpcClientPool) Put(c *IdleClient) error {
 	if c == nil {
 		return ERROR_NIL_CLIENT
 	}
 
+	err := p.validate(c)
+
 	p.Lock()
-	defer p.Unlock()
+	defer p.unlockReturned()
+
+	return p.put(c, err)
+}
+
+// PutAll give back a batch of connections with one lock acquisition, the
+// error of each connection is returned in the same order
+func (p *GRpcClientPool) PutAll(cs []*IdleClient) []error {
+	errs := make([]error, len(cs))
+	for i, c := range cs {
+		if c == nil {
+			errs[i] = ERROR_NIL_CLIENT
+		} else {
+			errs[i] = p.validate(c)
+		}
+	}
+
+	p.Lock()
+	defer p.unlockReturned()
+
+	for i, c := range cs {
+		if c != nil {
+			errs[i] = p.put(c, errs[i])
+		}
+	}
+
+	return errs
+}
 
-	if err := c.checkValid(); err != nil {
-		c.close()
-		if p.count > 0 {
-			p.count--
+// validate check c before giving back, p need not be locked
+func (p *GRpcClientPool) validate(c *IdleClient) error {
+	if p.closing.Load() {
+		// closed anyway
+		return nil
+	}
+	err := c.checkValid()
+	if err == nil && p.reviveIdle {
+		c.revive()
+	}
+	return err
+}
+
+// put give back c whose validation result is err, p MUST be locked
+func (p *GRpcClientPool) put(c *IdleClient, err error) error {
+	if _, ok := p.out[c]; !ok {
+		// given back twice, or after reclaimed or released
+		p.disown(c)
+		return ERROR_NOT_CHECKED_OUT
+	}
+
+	delete(p.out, c)
+	c.resetCheckout()
+	if err != nil || c.retireOnReturn || c.ephemeral || p.closing.Load() || p.draining || p.expired(c) {
+		p.retire(c)
+		if err != nil {
+			p.markOutage()
+			return ERROR_INVALID_CLIENT
+		}
+		return nil
+	}
+	if (p.maxIdle > 0 && p.pool.size() >= p.maxIdle) || (p.maxCount > 0 && p.count-p.overflow > p.maxCount) {
+		// trim the oldest, c or an idle one
+		old := p.oldestIdle()
+		if old == nil || !old.createdTime.Before(c.createdTime) {
+			p.retire(c)
+			return nil
 		}
-		return ERROR_INVALID_CLIENT
+		p.removeIdle(old)
+		p.retire(old)
 	}
 
 	c.updateLastCalledTime()
-	p.pool = append(p.pool, c)
+	p.addIdle(c)
 
 	return nil
 }
@@ -182,23 +1253,114 @@ func (p *GRpcClientPool) DelErrorClient(c *IdleClient) {
 		return
 	}
 
-	c.close()
 	p.Lock()
-	if p.count > 0 {
-		p.count--
+	if _, ok := p.out[c]; ok {
+		delete(p.out, c)
+		p.retire(c)
+		p.markOutage()
+	} else if p.removeIdle(c) {
+		p.retire(c)
+	} else {
+		p.disown(c)
 	}
+	p.unlockReturned()
+}
+
+// unlockReturned unlock p, then call onAllReturned if no conn is checked out
+func (p *GRpcClientPool) unlockReturned() {
+	all := len(p.out) == 0
 	p.Unlock()
+
+	if all && p.onAllReturned != nil {
+		p.onAllReturned()
+	}
 }
 
-func (p *GRpcClientPool) Release() {
+// disown close c not counted by the pool any more, unless it's idle in pool,
+// p MUST be locked
+func (p *GRpcClientPool) disown(c *IdleClient) {
+	if c.closed || p.pool.contains(c) {
+		return
+	}
+	p.closeClient(c)
+}
+
+// Evict close c if it's idle in pool, or mark it to be closed when given back
+// if it's checked out. It return false if c doesn't belong to the pool.
+func (p *GRpcClientPool) Evict(c *IdleClient) bool {
 	p.Lock()
 	defer p.Unlock()
 
-	for _, c := range p.pool {
-		if c != nil {
-			c.close()
+	if _, ok := p.out[c]; ok {
+		c.retireOnReturn = true
+		return true
+	}
+
+	if !p.removeIdle(c) {
+		return false
+	}
+	p.retire(c)
+
+	return true
+}
+
+// oldestIdle return the idle client created first, nil if none, p MUST be
+// locked
+func (p *GRpcClientPool) oldestIdle() *IdleClient {
+	var old *IdleClient
+	for i := 0; i < p.pool.size(); i++ {
+		if c := p.pool.at(i); old == nil || c.createdTime.Before(old.createdTime) {
+			old = c
+		}
+	}
+	return old
+}
+
+// idleClients return a copy of the idle clients, p MUST be locked
+func (p *GRpcClientPool) idleClients() []*IdleClient {
+	cs := make([]*IdleClient, p.pool.size())
+	for i := range cs {
+		cs[i] = p.pool.at(i)
+	}
+	return cs
+}
+
+// removeIdle remove c from idle pool, p MUST be locked
+func (p *GRpcClientPool) removeIdle(c *IdleClient) bool {
+	return p.pool.remove(c)
+}
+
+// Release close idle clients and stop background goroutines, it returns after
+// they exited. Dials in flight are cancelled and a conn dialed after Release
+// is closed, Get needing to dial return ERROR_POOL_CLOSED since.
+func (p *GRpcClientPool) Release() {
+	p.Lock()
+	p.release()
+	p.Unlock()
+
+	// background goroutines need the lock to notice done
+	p.wg.Wait()
+}
+
+// release stop background goroutines and close idle clients, lock must be
+// held
+func (p *GRpcClientPool) release() {
+	p.cancel()
+	p.stopBoosts()
+
+	for _, c := range p.pool.clear() {
+		p.closeClient(c)
+	}
+	// dials in flight hold their slots
+	p.addCount(len(p.dialing) - p.count)
+	p.overflow = 0
+	for call := range p.dialing {
+		if call.overflow {
+			p.overflow++
 		}
 	}
-	p.count = 0
-	p.pool = make([]*IdleClient, 0)
+	p.idlePeak = 0
+	p.out = make(map[*IdleClient]struct{})
+	// wake up WaitWarm to notice released
+	p.cond.Broadcast()
 }
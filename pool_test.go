@@ -0,0 +1,580 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"runtime"
+	"sync"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestGetOverTestServer(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Put(c); err != nil {
+		t.Fatal(err)
+	}
+
+	c2, err := p.Get()
+	if err != nil || c2 != c {
+		t.Fatalf("want the idle client back, got %v, %v", c2, err)
+	}
+}
+
+func TestGetNoDialWouldDial(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	if _, err := p.GetNoDial(context.Background()); err != ERROR_WOULD_DIAL {
+		t.Fatalf("want ERROR_WOULD_DIAL, got %v", err)
+	}
+	if n := p.Stats().Count; n != 0 {
+		t.Fatalf("GetNoDial dialed, count %v", n)
+	}
+}
+
+func TestGetNoDialIdle(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Put(c)
+
+	c2, err := p.GetNoDial(context.Background())
+	if err != nil || c2 != c {
+		t.Fatalf("want the idle client, got %v, %v", c2, err)
+	}
+}
+
+func TestGetNoDialJoinDialInFlight(t *testing.T) {
+	srv := newTestServer(t)
+	release := make(chan struct{})
+	dialF := srv.DialFunc()
+	p, _ := NewGRpcClientPoolE("bufnet", func(addr string) (*grpc.ClientConn, error) {
+		<-release
+		return dialF(addr)
+	}, 5, time.Minute)
+	defer p.Release()
+
+	got := make(chan *IdleClient)
+	go func() {
+		c, err := p.Get()
+		if err != nil {
+			t.Error(err)
+		}
+		got <- c
+	}()
+	waitUntil(t, func() bool { return p.Stats().Count == 1 })
+
+	joined := make(chan error)
+	go func() {
+		_, err := p.GetNoDial(context.Background())
+		joined <- err
+	}()
+	select {
+	case <-joined:
+		t.Fatal("GetNoDial returned before the dial in flight done")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	// the dial goes to its owner, nothing idle left and no dial of its own
+	close(release)
+	if c := <-got; c == nil {
+		t.Fatal("want the client dialed by Get")
+	}
+	if err := <-joined; err != ERROR_WOULD_DIAL {
+		t.Fatalf("want ERROR_WOULD_DIAL, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 1 || s.Dials != 1 {
+		t.Fatalf("want no extra dial, got %+v", s)
+	}
+}
+
+func TestGetNoDialJoinedDialFailed(t *testing.T) {
+	release := make(chan struct{})
+	p, _ := NewGRpcClientPoolE("bufnet", func(string) (*grpc.ClientConn, error) {
+		<-release
+		return nil, errors.New("down")
+	}, 5, time.Minute)
+	defer p.Release()
+
+	go p.Get()
+	waitUntil(t, func() bool { return p.Stats().Count == 1 })
+
+	errc := make(chan error)
+	go func() {
+		_, err := p.GetNoDial(context.Background())
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	if err := <-errc; !IsDialError(err) {
+		t.Fatalf("want the dial error, got %v", err)
+	}
+}
+
+func TestPauseFastFail(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	p.Pause()
+	if _, err := p.Get(); err != ERROR_POOL_PAUSED {
+		t.Fatalf("want ERROR_POOL_PAUSED, got %v", err)
+	}
+	p.Resume()
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPauseBlocks(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithPauseBlocks())
+
+	p.Pause()
+	done := make(chan error)
+	go func() {
+		_, err := p.Get()
+		done <- err
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetNoDial(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want blocked until ctx done, got %v", err)
+	}
+	select {
+	case err := <-done:
+		t.Fatalf("Get not blocked while paused: %v", err)
+	default:
+	}
+
+	p.Resume()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultDialFuncLazy(t *testing.T) {
+	srv := newTestServer(t)
+	p, err := NewGRpcClientPoolE("passthrough:///bufnet", nil, 5, time.Minute, WithDialOptions(grpc.WithContextDialer(srv.DialContext)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Release()
+
+	c, err := p.Get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.GetConn().GetState(); s != connectivity.Idle {
+		t.Fatalf("want a lazy client Idle, got %v", s)
+	}
+	if err := p.Put(c); err != nil {
+		t.Fatalf("want an Idle client valid, got %v", err)
+	}
+
+	c, _ = p.Get()
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.GetConn().GetState(); s != connectivity.Ready {
+		t.Fatalf("want the client connected by the first rpc, got %v", s)
+	}
+	if err := p.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); s.Idle != 1 || s.Dials != 1 {
+		t.Fatalf("want one client dialed and pooled, got %+v", s)
+	}
+}
+
+func TestPutReviveIdle(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithReviveIdle())
+
+	c, _ := p.Get()
+	if s := c.GetConn().GetState(); s != connectivity.Idle {
+		t.Fatalf("want a lazy client Idle, got %v", s)
+	}
+	if err := p.Put(c); err != nil {
+		t.Fatal(err)
+	}
+	if s := c.GetConn().GetState(); s == connectivity.Idle {
+		t.Fatal("Put didn't kick the Idle client to connect")
+	}
+}
+
+func TestPutRejectClosed(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	c.GetConn().Close()
+	if err := p.Put(c); err != ERROR_INVALID_CLIENT {
+		t.Fatalf("want ERROR_INVALID_CLIENT, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 || s.Idle != 0 {
+		t.Fatalf("want the closed client dropped, got %+v", s)
+	}
+}
+
+func TestPutAll(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxIdle(2))
+
+	var cs []*IdleClient
+	for i := 0; i < 4; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	cs[1].GetConn().Close()
+
+	errs := p.PutAll(append(cs, nil))
+	for i, want := range []error{nil, ERROR_INVALID_CLIENT, nil, nil, ERROR_NIL_CLIENT} {
+		if errs[i] != want {
+			t.Errorf("client %v: want %v, got %v", i, want, errs[i])
+		}
+	}
+	if s := p.Stats(); s.Idle != 2 || s.Count != 2 {
+		t.Fatalf("want 2 idle kept by MaxIdle, got %+v", s)
+	}
+}
+
+func TestWaitWarm(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		p.Put(a)
+		time.Sleep(20 * time.Millisecond)
+		p.Put(b)
+	}()
+	if err := p.WaitWarm(context.Background(), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+	defer cancel()
+	if err := p.WaitWarm(ctx, 3); err != context.DeadlineExceeded {
+		t.Fatalf("want ctx done, got %v", err)
+	}
+}
+
+func TestWaitWarmOverMaxCount(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute)
+
+	if err := p.WaitWarm(context.Background(), 3); err == nil {
+		t.Fatal("want waiting for more than maxCount rejected")
+	}
+}
+
+func TestWaitWarmReleased(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute)
+
+	errc := make(chan error)
+	go func() { errc <- p.WaitWarm(context.Background(), 2) }()
+	time.Sleep(20 * time.Millisecond)
+	p.Release()
+	if err := <-errc; err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED, got %v", err)
+	}
+	if err := p.WaitWarm(context.Background(), 1); err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED, got %v", err)
+	}
+}
+
+func TestEvict(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	if !p.Evict(a) {
+		t.Fatal("want the idle client evicted")
+	}
+	if !p.Evict(b) {
+		t.Fatal("want the checked out client flagged")
+	}
+	if p.Evict(a) {
+		t.Fatal("want an evicted client not found")
+	}
+	if s := p.Stats(); s.Count != 1 || s.Idle != 0 {
+		t.Fatalf("want only b left, got %+v", s)
+	}
+
+	p.Put(b)
+	if s := p.Stats(); s.Count != 0 || s.Idle != 0 {
+		t.Fatalf("want b retired on Put, got %+v", s)
+	}
+}
+
+func TestGetSkipStale(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, 50*time.Millisecond)
+
+	a, _ := p.Get()
+	p.Put(a)
+	b, _ := p.Get()
+	if b != a {
+		t.Fatal("want the fresh client reused")
+	}
+	p.Put(b)
+
+	// nothing is stale until the idle deadline of a
+	p.Lock()
+	next := p.nextExpire
+	p.Unlock()
+	if !next.After(time.Now()) {
+		t.Fatalf("want the next expiry in the future, got %v", next)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	c, _ := p.Get()
+	if c == a {
+		t.Fatal("want the stale client not reused")
+	}
+	if s := p.Stats(); s.Timeouts != 1 || s.Count != 1 {
+		t.Fatalf("want the stale client closed, got %+v", s)
+	}
+}
+
+// BenchmarkGetPut compare Get and Put on a fresh pool skipping the staleness
+// scan with one forced to scan each Get
+func BenchmarkGetPut(b *testing.B) {
+	for _, bc := range []struct {
+		name string
+		scan bool
+	}{{"fresh", false}, {"scan", true}} {
+		b.Run(bc.name, func(b *testing.B) {
+			p := newTestPool(b, newTestServer(b), 64, time.Minute)
+			var cs []*IdleClient
+			for i := 0; i < 64; i++ {
+				c, err := p.Get()
+				if err != nil {
+					b.Fatal(err)
+				}
+				cs = append(cs, c)
+			}
+			p.PutAll(cs)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if bc.scan {
+					p.Lock()
+					p.nextExpire = time.Time{}
+					p.Unlock()
+				}
+				c, err := p.Get()
+				if err != nil {
+					b.Fatal(err)
+				}
+				p.Put(c)
+			}
+		})
+	}
+}
+
+func TestReleaseStopBackground(t *testing.T) {
+	srv := newTestServer(t)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 20; i++ {
+		p := newTestPool(t, srv, 5, time.Minute, WithReaper(time.Millisecond), WithLeaseTimeout(time.Millisecond), WithStateWatching())
+		c, _ := p.Get()
+		checkHealth(c)
+		p.Put(c)
+		p.Release()
+		p.Release()
+	}
+
+	// connections closed by Release exit asynchronously in gRPC
+	waitUntil(t, func() bool { return runtime.NumGoroutine() <= before })
+}
+
+func TestClientIDUnique(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute)
+	p2 := newTestPool(t, newTestServer(t), 0, time.Minute)
+
+	seen := make(map[string]bool)
+	for i := 0; i < 10; i++ {
+		for _, pool := range []*GRpcClientPool{p, p2} {
+			c, _ := pool.Get()
+			if id := c.ID(); id == "" || seen[id] {
+				t.Fatalf("want a unique id, got %q", id)
+			}
+			seen[c.ID()] = true
+		}
+	}
+}
+
+func TestGetFresh(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 3, time.Minute)
+
+	c, _ := p.Get()
+	p.Put(c)
+	f, err := p.GetFresh(context.Background(), WithNoPoolReturn())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := p.Stats(); f == c || s.Dials != 2 || s.Idle != 1 {
+		t.Fatalf("want a fresh dial with the idle client left, got %+v", s)
+	}
+	p.Put(f)
+	if s := p.Stats(); s.Count != 1 || s.Idle != 1 {
+		t.Fatalf("want the no pool return client closed, got %+v", s)
+	}
+
+	f, _ = p.GetFresh(context.Background())
+	p.Put(f)
+	if s := p.Stats(); s.Count != 2 || s.Idle != 2 {
+		t.Fatalf("want the fresh client pooled, got %+v", s)
+	}
+
+	p.GetFresh(context.Background())
+	if _, err := p.GetFresh(context.Background()); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+}
+
+func TestReleaseDuringDial(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	var (
+		mu     sync.Mutex
+		dialed []*grpc.ClientConn
+	)
+	slow := func(addr string) (*grpc.ClientConn, error) {
+		time.Sleep(100 * time.Millisecond)
+		conn, err := dial(addr)
+		mu.Lock()
+		dialed = append(dialed, conn)
+		mu.Unlock()
+		return conn, err
+	}
+	p, _ := NewGRpcClientPoolE("bufnet", slow, 5, time.Minute)
+
+	errc := make(chan error)
+	go func() {
+		_, err := p.GetContext(context.Background())
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Release()
+	if err := <-errc; err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED, got %v", err)
+	}
+
+	// the late connection is closed
+	waitUntil(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(dialed) == 1 && dialed[0].GetState() == connectivity.Shutdown
+	})
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want nothing counted, got %+v", s)
+	}
+}
+
+func TestReleaseDuringOnDial(t *testing.T) {
+	slowHook := WithoutContext(func(*IdleClient) error {
+		time.Sleep(80 * time.Millisecond)
+		return nil
+	})
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithOnDial(slowHook))
+
+	errc := make(chan error)
+	go func() {
+		_, err := p.Get()
+		errc <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	p.Release()
+	if err := <-errc; err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED, got %v", err)
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want nothing counted, got %+v", s)
+	}
+}
+
+func TestStaleScanInterval(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, 20*time.Millisecond, WithStaleScanInterval(50*time.Millisecond))
+
+	a, _ := p.Get() // scans
+	p.Put(a)
+	time.Sleep(30 * time.Millisecond)
+	// throttled, the stale client is handed out
+	if c, _ := p.Get(); c != a || p.Stats().Timeouts != 0 {
+		t.Fatalf("want the scan throttled, got %+v", p.Stats())
+	}
+	p.Put(a)
+
+	time.Sleep(30 * time.Millisecond)
+	if c, _ := p.Get(); c == a || p.Stats().Timeouts != 1 {
+		t.Fatalf("want the scan once the interval passed, got %+v", p.Stats())
+	}
+}
+
+// BenchmarkStaleScanInterval compare Get and Put scanning on each Get with
+// the scan throttled
+func BenchmarkStaleScanInterval(b *testing.B) {
+	for _, d := range []time.Duration{0, time.Second} {
+		b.Run(d.String(), func(b *testing.B) {
+			p := newTestPool(b, newTestServer(b), 0, time.Hour, WithStaleScanInterval(d))
+			var cs []*IdleClient
+			for i := 0; i < 64; i++ {
+				c, _ := p.Get()
+				cs = append(cs, c)
+			}
+			p.PutAll(cs)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				c, _ := p.Get()
+				p.Put(c)
+			}
+		})
+	}
+}
+
+func TestMaxIdleEvictOldest(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxIdle(2))
+
+	a, _ := p.Get()
+	time.Sleep(time.Millisecond)
+	b, _ := p.Get()
+	time.Sleep(time.Millisecond)
+	c, _ := p.Get()
+	if !a.CreatedAt().Before(b.CreatedAt()) {
+		t.Fatal("want a created before b")
+	}
+
+	// the one given back is the oldest
+	p.Put(c)
+	p.Put(b)
+	p.Put(a)
+	if !isClosed(p, a) || isClosed(p, b) || isClosed(p, c) {
+		t.Fatal("want a closed")
+	}
+
+	// f dialed last kept, b the oldest closed
+	d, _ := p.Get()
+	e, _ := p.Get()
+	f, _ := p.Get()
+	p.Put(f)
+	p.Put(d)
+	p.Put(e)
+	if s := p.Stats(); s.Idle != 2 || !isClosed(p, b) || isClosed(p, f) {
+		t.Fatalf("want b closed, got %+v", s)
+	}
+}
@@ -0,0 +1,128 @@
+// Package poolhttp serves the Stats of grpc_pool over net/http, as JSON by
+// default or as Prometheus text exposition with ?format=prometheus.
+// Credential keys of GetPoolFor are never served, only a hash of them.
+//
+// FOR EXAMPLE:
+//
+//	http.Handle("/debug/grpc_pool", poolhttp.MapPoolHandler(mapPool))
+package poolhttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/SongLiangChen/grpc_pool"
+)
+
+// Handler serve the Stats of p
+func Handler(p *grpc_pool.GRpcClientPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := p.Stats()
+		if isPrometheus(r) {
+			writePrometheus(w, []grpc_pool.PoolStats{{Stats: stats}})
+			return
+		}
+		writeJSON(w, stats)
+	})
+}
+
+// MapPoolHandler serve the Stats of all pools in mp, credential keys hashed
+func MapPoolHandler(mp *grpc_pool.MapPool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stats := mp.Stats()
+		for i := range stats {
+			stats[i].CredKey = hashCred(stats[i].CredKey)
+		}
+		if isPrometheus(r) {
+			writePrometheus(w, stats)
+			return
+		}
+		writeJSON(w, stats)
+	})
+}
+
+// hashCred return a short hash of cred telling pools apart without revealing
+// it, "" for no credentials
+func hashCred(cred string) string {
+	if cred == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(cred))
+	return hex.EncodeToString(sum[:8])
+}
+
+func isPrometheus(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "prometheus"
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// metric is a gauge or counter exported from Stats
+type metric struct {
+	name  string
+	typ   string
+	help  string
+	value func(s grpc_pool.Stats) float64
+}
+
+var metrics = []metric{
+	{"grpc_pool_connections", "gauge", "Connections in pool, both idle and in use.", func(s grpc_pool.Stats) float64 { return float64(s.Count) }},
+	{"grpc_pool_idle_connections", "gauge", "Idle connections in pool.", func(s grpc_pool.Stats) float64 { return float64(s.Idle) }},
+	{"grpc_pool_in_use_connections", "gauge", "Connections taken by Get and not given back yet.", func(s grpc_pool.Stats) float64 { return float64(s.InUse) }},
+	{"grpc_pool_overflow_connections", "gauge", "Ephemeral connections dialed beyond max connections.", func(s grpc_pool.Stats) float64 { return float64(s.Overflow) }},
+	{"grpc_pool_max_connections", "gauge", "Max size of pool, 0 means no limit.", func(s grpc_pool.Stats) float64 { return float64(s.MaxCount) }},
+	{"grpc_pool_avg_conn_age_seconds", "gauge", "Average age of connections.", func(s grpc_pool.Stats) float64 { return s.AvgConnAge.Seconds() }},
+	{"grpc_pool_avg_reuses", "gauge", "Average times connections have been taken by Get again.", func(s grpc_pool.Stats) float64 { return s.AvgReuses }},
+	{"grpc_pool_warm_score", "gauge", "Ratio of idle connections.", func(s grpc_pool.Stats) float64 { return s.WarmScore }},
+	{"grpc_pool_dials_total", "counter", "Connections dialed.", func(s grpc_pool.Stats) float64 { return float64(s.Dials) }},
+	{"grpc_pool_dial_errors_total", "counter", "Dials failed.", func(s grpc_pool.Stats) float64 { return float64(s.DialErrors) }},
+	{"grpc_pool_timeouts_total", "counter", "Connections closed as idle timeout or outlived.", func(s grpc_pool.Stats) float64 { return float64(s.Timeouts) }},
+	{"grpc_pool_overflow_dials_total", "counter", "Ephemeral connections dialed beyond max connections.", func(s grpc_pool.Stats) float64 { return float64(s.OverflowDials) }},
+	{"grpc_pool_reuses_total", "counter", "Gets served by idle connections.", func(s grpc_pool.Stats) float64 { return float64(s.Reuses) }},
+	{"grpc_pool_rpc_success_total", "counter", "Rpcs succeeded.", func(s grpc_pool.Stats) float64 { return float64(s.RPCSuccess) }},
+	{"grpc_pool_rpc_failure_total", "counter", "Rpcs failed.", func(s grpc_pool.Stats) float64 { return float64(s.RPCFailure) }},
+	{"grpc_pool_fd_exhausted", "gauge", "1 if dialing stopped as file descriptors exhausted.", func(s grpc_pool.Stats) float64 { return boolValue(s.FDExhausted) }},
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// writePrometheus write stats in Prometheus text format, pools without an
+// address are written without the addr label, and pools for credentials with
+// a cred label of the hashed credentials
+func writePrometheus(w http.ResponseWriter, stats []grpc_pool.PoolStats) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range metrics {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", m.name, m.help, m.name, m.typ)
+		for _, s := range stats {
+			writeSample(w, m.name, s.Addr, s.CredKey, m.value(s.Stats))
+		}
+	}
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func writeSample(w io.Writer, name, addr, cred string, value float64) {
+	switch {
+	case addr == "":
+		fmt.Fprintf(w, "%s %v\n", name, value)
+	case cred == "":
+		fmt.Fprintf(w, "%s{addr=\"%s\"} %v\n", name, labelEscaper.Replace(addr), value)
+	default:
+		fmt.Fprintf(w, "%s{addr=\"%s\",cred=\"%s\"} %v\n", name, labelEscaper.Replace(addr), labelEscaper.Replace(cred), value)
+	}
+}
@@ -0,0 +1,90 @@
+package poolhttp
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool"
+	"github.com/SongLiangChen/grpc_pool/testutil"
+)
+
+func newTestMapPool(t *testing.T) *grpc_pool.MapPool {
+	srv := testutil.NewServer(nil)
+	t.Cleanup(srv.Stop)
+
+	mp := grpc_pool.NewMapPool(srv.DialFunc(), 5, time.Minute)
+	t.Cleanup(mp.ReleaseAllPool)
+	if _, err := mp.GetPool("a:1").Get(); err != nil {
+		t.Fatal(err)
+	}
+	return mp
+}
+
+func TestHandlerJSON(t *testing.T) {
+	mp := newTestMapPool(t)
+
+	rec := httptest.NewRecorder()
+	Handler(mp.GetPool("a:1")).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var s grpc_pool.Stats
+	if err := json.Unmarshal(rec.Body.Bytes(), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s.Count != 1 || s.InUse != 1 {
+		t.Fatalf("want 1 in use, got %+v", s)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Fatalf("want JSON, got %v", ct)
+	}
+}
+
+func TestMapPoolHandlerJSON(t *testing.T) {
+	mp := newTestMapPool(t)
+
+	rec := httptest.NewRecorder()
+	MapPoolHandler(mp).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	var stats []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &stats); err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 1 || stats[0]["Addr"] != "a:1" || stats[0]["Count"].(float64) != 1 {
+		t.Fatalf("want the pool of a:1, got %v", rec.Body.String())
+	}
+}
+
+func TestMapPoolHandlerPrometheus(t *testing.T) {
+	mp := newTestMapPool(t)
+
+	rec := httptest.NewRecorder()
+	MapPoolHandler(mp).ServeHTTP(rec, httptest.NewRequest("GET", "/?format=prometheus", nil))
+	body := rec.Body.String()
+	for _, want := range []string{
+		"# TYPE grpc_pool_connections gauge",
+		`grpc_pool_connections{addr="a:1"} 1`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("want %q in\n%v", want, body)
+		}
+	}
+}
+
+func TestMapPoolHandlerHideCred(t *testing.T) {
+	mp := newTestMapPool(t)
+	if _, err := mp.GetPoolFor("a:1", "secret-token").Get(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, target := range []string{"/", "/?format=prometheus"} {
+		rec := httptest.NewRecorder()
+		MapPoolHandler(mp).ServeHTTP(rec, httptest.NewRequest("GET", target, nil))
+		body := rec.Body.String()
+		if strings.Contains(body, "secret-token") {
+			t.Fatalf("want the credential key hidden from %v, got\n%v", target, body)
+		}
+		if !strings.Contains(body, hashCred("secret-token")) {
+			t.Fatalf("want the hashed credential key in %v, got\n%v", target, body)
+		}
+	}
+}
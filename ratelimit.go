@@ -0,0 +1,30 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// dialLimiter allow at most n dials in any window of per
+type dialLimiter struct {
+	n   int
+	per time.Duration
+
+	// Start time of recent dials, oldest first
+	times []time.Time
+}
+
+// allow record a dial and return true if it's allowed now
+func (l *dialLimiter) allow(now time.Time) bool {
+	index := 0
+	for index < len(l.times) && now.Sub(l.times[index]) >= l.per {
+		index++
+	}
+	l.times = l.times[index:]
+
+	if len(l.times) >= l.n {
+		return false
+	}
+
+	l.times = append(l.times, now)
+	return true
+}
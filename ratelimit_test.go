@@ -0,0 +1,41 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDialLimiterWindow(t *testing.T) {
+	l := &dialLimiter{n: 2, per: time.Second}
+	now := time.Now()
+
+	if !l.allow(now) || !l.allow(now.Add(100*time.Millisecond)) {
+		t.Fatal("want 2 dials allowed")
+	}
+	if l.allow(now.Add(500 * time.Millisecond)) {
+		t.Fatal("want the third dial in the window denied")
+	}
+	if !l.allow(now.Add(time.Second)) {
+		t.Fatal("want a dial allowed once the first left the window")
+	}
+}
+
+func TestDialRateLimitRapidRetirements(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute, WithDialRateLimit(3, 100*time.Millisecond))
+
+	for i := 0; i < 3; i++ {
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.DelErrorClient(c)
+	}
+	if _, err := p.Get(); err != ERROR_DIAL_RATE_LIMITED {
+		t.Fatalf("want ERROR_DIAL_RATE_LIMITED, got %v", err)
+	}
+
+	time.Sleep(110 * time.Millisecond)
+	if _, err := p.Get(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package grpc_pool
+
+import (
+	"context"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// WaitReady block until c is Ready, kicking it to connect if Idle. gRPC
+// moves a connection through Connecting and TransientFailure with backoff
+// while the server is down, WaitReady keeps waiting through them, and fails
+// if c is shut down or ctx done.
+func (p *GRpcClientPool) WaitReady(ctx context.Context, c *IdleClient) error {
+	return waitReady(ctx, c, false)
+}
+
+// waitReady is WaitReady, but fail on TransientFailure too if failFast
+func waitReady(ctx context.Context, c *IdleClient, failFast bool) error {
+	for {
+		state := c.conn.GetState()
+		switch state {
+		case connectivity.Ready:
+			return nil
+		case connectivity.Shutdown:
+			return ERROR_INVALID_CLIENT
+		case connectivity.TransientFailure:
+			if failFast {
+				return ERROR_INVALID_CLIENT
+			}
+		case connectivity.Idle:
+			c.conn.Connect()
+		}
+
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return ctx.Err()
+		}
+	}
+}
+
+// GetReady is like GetContext, but the connection returned is Ready. The
+// connection is given back if ctx done before it's Ready, or retired if it's
+// shut down.
+func (p *GRpcClientPool) GetReady(ctx context.Context) (*IdleClient, error) {
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = p.WaitReady(ctx, c); err != nil {
+		if ctx.Err() != nil {
+			p.Put(c)
+		} else {
+			p.DelErrorClient(c)
+		}
+		return nil, err
+	}
+
+	return c, nil
+}
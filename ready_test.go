@@ -0,0 +1,70 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestGetReadyServerComesUp(t *testing.T) {
+	srv := newTestServer(t)
+	// the server is down until up, dials fail and the connection bounce
+	// through TransientFailure
+	var up atomic.Bool
+	dialer := func(ctx context.Context, addr string) (net.Conn, error) {
+		if !up.Load() {
+			return nil, errors.New("server down")
+		}
+		return srv.DialContext(ctx, addr)
+	}
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithDialOptions(
+		grpc.WithContextDialer(dialer),
+		grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.Config{BaseDelay: 10 * time.Millisecond, MaxDelay: 20 * time.Millisecond}}),
+	))
+	time.AfterFunc(100*time.Millisecond, func() { up.Store(true) })
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	c, err := p.GetReady(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := c.GetConn().GetState(); s != connectivity.Ready {
+		t.Fatalf("want Ready, got %v", s)
+	}
+}
+
+func TestGetReadyCtxDone(t *testing.T) {
+	srv := newTestServer(t)
+	dialer := func(context.Context, string) (net.Conn, error) {
+		return nil, errors.New("server down")
+	}
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithDialOptions(grpc.WithContextDialer(dialer)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetReady(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+	// given back, and dropped by Put as in TransientFailure
+	if s := p.Stats(); s.InUse != 0 || s.Count != 0 {
+		t.Fatalf("want the client given back, got %+v", s)
+	}
+}
+
+func TestWaitReadyShutdown(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	c.GetConn().Close()
+	if err := p.WaitReady(context.Background(), c); err != ERROR_INVALID_CLIENT {
+		t.Fatalf("want ERROR_INVALID_CLIENT, got %v", err)
+	}
+}
@@ -0,0 +1,109 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// The reaper don't compact idle pools smaller than it, not worth it
+const minCompactCap = 64
+
+// Connections are retired 1/10 of the server max connection age early by
+// default, see WithServerMaxConnAge
+const defaultConnAgeMarginRatio = 10
+
+// expired report whether c outlived its lifetime, maxIdleReuses or poison
+func (p *GRpcClientPool) expired(c *IdleClient) bool {
+	lifetime := p.lifetime()
+	return (lifetime > 0 && time.Since(c.createdTime) >= lifetime) ||
+		(p.maxIdleReuses > 0 && c.idleReuses >= p.maxIdleReuses) ||
+		c.poisonExpired()
+}
+
+// lifetime return the max age of connections, the shorter of maxLifetime and
+// the server max connection age less margin, 0 means no limit
+func (p *GRpcClientPool) lifetime() time.Duration {
+	if p.serverMaxConnAge <= 0 {
+		return p.maxLifetime
+	}
+
+	margin := p.connAgeMargin
+	if margin <= 0 {
+		margin = p.serverMaxConnAge / defaultConnAgeMarginRatio
+	}
+	age := p.serverMaxConnAge - margin
+	if age <= 0 {
+		age = p.serverMaxConnAge
+	}
+	if p.maxLifetime > 0 && p.maxLifetime < age {
+		return p.maxLifetime
+	}
+
+	return age
+}
+
+// reaper remove stale clients every reapInterval until the pool released
+func (p *GRpcClientPool) reaper() {
+	t := time.NewTicker(p.reapInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-t.C:
+			p.reap()
+		}
+	}
+}
+
+// reap remove stale idle clients, and mark checked out clients outlived
+// their lifetime to be retired when given back
+func (p *GRpcClientPool) reap() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.delStaleClients()
+	p.compact(false)
+
+	for c := range p.out {
+		if p.expired(c) {
+			c.retireOnReturn = true
+		}
+	}
+}
+
+// Compact reallocate the idle pool to fit, releasing memory retained after
+// heavy churn. The reaper does it when much of the capacity is unused.
+func (p *GRpcClientPool) Compact() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.compact(true)
+}
+
+// compact reallocate the idle pool if forced or it shrank below a quarter of
+// its peak, p MUST be locked. The peak rather than cap is checked, as taking
+// from the head reslice the pool hiding capacity still retained.
+func (p *GRpcClientPool) compact(force bool) {
+	if !force && (p.idlePeak < minCompactCap || p.pool.size() > p.idlePeak/4) {
+		return
+	}
+
+	p.pool.compact()
+	p.idlePeak = p.pool.size()
+}
+
+// SetIdleTimeout change the idle timeout at runtime, it applies to idle
+// connections now too. Connections stale by the new timeout are closed on
+// the next Get or reap, which is forced even if throttled by
+// WithStaleScanInterval. A d of 0 means no idle timeout.
+func (p *GRpcClientPool) SetIdleTimeout(d time.Duration) {
+	p.Lock()
+	defer p.Unlock()
+
+	p.idleTimeout = d
+	p.pool.rekey(p.idleDeadline)
+	// unknown until the next scan
+	p.nextExpire = time.Time{}
+	p.lastScan = time.Time{}
+}
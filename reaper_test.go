@@ -0,0 +1,180 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestReaperFlagOutlivedCheckedOut(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxLifetime(50*time.Millisecond), WithReaper(10*time.Millisecond))
+
+	c, _ := p.Get()
+	waitUntil(t, func() bool {
+		p.Lock()
+		defer p.Unlock()
+		return c.retireOnReturn
+	})
+
+	p.Put(c)
+	if s := p.Stats(); s.Idle != 0 || s.Count != 0 {
+		t.Fatalf("want the outlived client retired on Put, got %+v", s)
+	}
+}
+
+func TestReapCompact(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute)
+
+	var cs []*IdleClient
+	for i := 0; i < 200; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	p.PutAll(cs)
+	for i := 0; i < 190; i++ {
+		c, _ := p.Get()
+		p.DelErrorClient(c)
+	}
+
+	p.reap()
+	p.Lock()
+	entries := p.pool.(*sliceStore).entries
+	peak := p.idlePeak
+	p.Unlock()
+	if len(entries) != 10 || cap(entries) != 10 || peak != 10 {
+		t.Fatalf("want the idle pool compacted to 10, got len %v cap %v peak %v", len(entries), cap(entries), peak)
+	}
+}
+
+func TestReapNotCompactSmall(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute)
+
+	var cs []*IdleClient
+	for i := 0; i < minCompactCap-1; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	p.PutAll(cs)
+	c, _ := p.Get()
+
+	p.reap()
+	p.Lock()
+	peak := p.idlePeak
+	p.Unlock()
+	if peak != minCompactCap-1 {
+		t.Fatalf("want a small pool not compacted, got peak %v", peak)
+	}
+
+	p.Put(c)
+	p.Compact()
+	p.Lock()
+	entries := p.pool.(*sliceStore).entries
+	p.Unlock()
+	if cap(entries) != len(entries) {
+		t.Fatalf("want Compact forced, got len %v cap %v", len(entries), cap(entries))
+	}
+}
+
+// BenchmarkCompact churn an idle store to 1024 and down to 24 by the head,
+// reporting the capacity retained before and after compact
+func BenchmarkCompact(b *testing.B) {
+	clients := make([]*IdleClient, 1024)
+	for i := range clients {
+		clients[i] = &IdleClient{}
+	}
+	deadline := time.Now().Add(time.Minute)
+
+	var before, after int
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s := &sliceStore{}
+		for _, c := range clients {
+			s.push(c, deadline)
+		}
+		for s.size() > 24 {
+			s.removeAt(0)
+		}
+		before = cap(s.entries)
+		s.compact()
+		after = cap(s.entries)
+	}
+	b.ReportMetric(float64(before), "cap-before")
+	b.ReportMetric(float64(after), "cap-after")
+}
+
+func TestMaxIdleReuses(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithMaxIdleReuses(2))
+
+	// dialed fresh, not an idle reuse
+	c, _ := p.Get()
+	p.Put(c)
+	for i := 0; i < 2; i++ {
+		if d, _ := p.Get(); d != c {
+			t.Fatalf("want c reused from idle, round %v", i)
+		}
+		p.Put(c)
+	}
+
+	p.Lock()
+	closed := c.closed
+	p.Unlock()
+	if s := p.Stats(); s.Count != 0 || !closed {
+		t.Fatalf("want c retired after 2 idle reuses, got %+v", s)
+	}
+	if d, _ := p.Get(); d == c {
+		t.Fatal("want a new client")
+	}
+}
+
+func TestSetIdleTimeout(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Hour, WithStaleScanInterval(time.Hour))
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	p.Put(b)
+	time.Sleep(20 * time.Millisecond)
+
+	// stale by the new timeout, closed on the next Get though throttled
+	p.SetIdleTimeout(10 * time.Millisecond)
+	c, _ := p.Get()
+	if c == a || c == b || p.Stats().Timeouts != 2 {
+		t.Fatalf("want the stale clients closed, got %+v", p.Stats())
+	}
+	if d := p.Config().IdleTimeout; d != 10*time.Millisecond {
+		t.Fatalf("want the new timeout in Config, got %v", d)
+	}
+}
+
+func TestSetIdleTimeoutReaper(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Hour, WithReaper(5*time.Millisecond))
+
+	c, _ := p.Get()
+	p.Put(c)
+	p.SetIdleTimeout(time.Millisecond)
+	waitUntil(t, func() bool { return p.Stats().Count == 0 })
+}
+
+func TestServerMaxConnAge(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute,
+		WithServerMaxConnAge(100*time.Millisecond), WithServerConnAgeMargin(50*time.Millisecond))
+
+	c, _ := p.Get()
+	p.Put(c)
+	time.Sleep(60 * time.Millisecond)
+	if d, _ := p.Get(); d == c || !isClosed(p, c) {
+		t.Fatal("want c retired before the server max age")
+	}
+}
+
+func TestServerMaxConnAgeLifetime(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithServerMaxConnAge(100*time.Millisecond))
+	if d := p.lifetime(); d != 90*time.Millisecond {
+		t.Fatalf("want the default margin of 1/10, got %v", d)
+	}
+
+	p = newTestPool(t, srv, 5, time.Minute, WithServerMaxConnAge(100*time.Millisecond), WithMaxLifetime(time.Millisecond))
+	if d := p.lifetime(); d != time.Millisecond {
+		t.Fatalf("want the shorter maxLifetime, got %v", d)
+	}
+}
@@ -0,0 +1,73 @@
+package grpc_pool
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Do retry at most doMaxRetries times a call, and a retry budget hold at
+// most retryBudgetCap tokens deposited by calls
+const (
+	doMaxRetries   = 2
+	retryBudgetCap = 100
+)
+
+// WithRetryBudget make Do retry a call on another connection when the retire
+// policy consider its error means the connection is bad, as long as the
+// budget shared by all calls allows, like gRPC retry throttling: each call
+// deposit ratio tokens and each retry take one, besides minPerSec retries
+// always allowed per second. Do doesn't retry without it.
+func WithRetryBudget(ratio float64, minPerSec int) Option {
+	return func(p *GRpcClientPool) {
+		if ratio < 0 || minPerSec < 0 || (ratio == 0 && minPerSec == 0) {
+			p.invalid(fmt.Errorf("Invalid retry budget, ratio[%v] minPerSec[%v]", ratio, minPerSec))
+			return
+		}
+		p.retries = &retryBudget{ratio: ratio, minPerSec: minPerSec}
+	}
+}
+
+// retryBudget is a token bucket of retries filled by calls, plus a reserve
+// refilled to minPerSec every second
+type retryBudget struct {
+	ratio     float64
+	minPerSec int
+
+	tokens   float64
+	reserve  int
+	reserved time.Time
+
+	sync.Mutex
+}
+
+// deposit add tokens of a call
+func (b *retryBudget) deposit() {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.tokens += b.ratio; b.tokens > retryBudgetCap {
+		b.tokens = retryBudgetCap
+	}
+}
+
+// withdraw take a token for a retry at now, return false if none left
+func (b *retryBudget) withdraw(now time.Time) bool {
+	b.Lock()
+	defer b.Unlock()
+
+	if now.Sub(b.reserved) >= time.Second {
+		b.reserve, b.reserved = b.minPerSec, now
+	}
+
+	switch {
+	case b.reserve > 0:
+		b.reserve--
+	case b.tokens >= 1:
+		b.tokens--
+	default:
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,68 @@
+package grpc_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestRetryBudgetSaturated(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute, WithRetryBudget(0.5, 1))
+
+	calls := 0
+	fail := func(*grpc.ClientConn) error {
+		calls++
+		return status.Error(codes.Unavailable, "down")
+	}
+
+	// the deposit of 0.5 and the reserve of 1 allow one retry
+	p.Do(context.Background(), fail)
+	if calls != 2 {
+		t.Fatalf("want 1 retry, got %v calls", calls)
+	}
+
+	// 0.5 left and 10 deposits of 0.5 allow 5 retries for 10 calls
+	calls = 0
+	for i := 0; i < 10; i++ {
+		p.Do(context.Background(), fail)
+	}
+	if calls != 15 {
+		t.Fatalf("want 5 retries, got %v calls", calls)
+	}
+}
+
+func TestRetryBudgetNotRetired(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute, WithRetryBudget(1, 10))
+
+	calls := 0
+	p.Do(context.Background(), func(*grpc.ClientConn) error {
+		calls++
+		return status.Error(codes.NotFound, "")
+	})
+	if calls != 1 {
+		t.Fatalf("want errors kept by the retire policy not retried, got %v calls", calls)
+	}
+}
+
+func TestRetryBudgetUnset(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 0, time.Minute)
+
+	calls := 0
+	p.Do(context.Background(), func(*grpc.ClientConn) error {
+		calls++
+		return status.Error(codes.Unavailable, "down")
+	})
+	if calls != 1 {
+		t.Fatalf("want no retry without a budget, got %v calls", calls)
+	}
+}
+
+func TestRetryBudgetInvalid(t *testing.T) {
+	if _, err := NewGRpcClientPoolE("bufnet", nil, 0, 0, WithRetryBudget(-1, 0)); err == nil {
+		t.Fatal("want a negative ratio invalid")
+	}
+}
@@ -0,0 +1,95 @@
+package grpc_pool
+
+import (
+	"context"
+	"sync/atomic"
+
+	"google.golang.org/grpc/stats"
+)
+
+// WithStatsTracking count rpcs and payload bytes per connection, read by
+// IdleClient.RPCCount and RPCBytes. It installs a stats.Handler by dial
+// options, so needs a DialOptionsFunc as WithDialOptions.
+func WithStatsTracking() Option {
+	return func(p *GRpcClientPool) {
+		p.statsTracking = true
+	}
+}
+
+// rpcStats is the stats.Handler of one connection
+type rpcStats struct {
+	rpcs     atomic.Int64
+	sent     atomic.Int64
+	received atomic.Int64
+
+	// Results of rpcs counted for the pool
+	results *rpcResults
+}
+
+// rpcResults count rpcs succeeded and failed in a pool, fed by the stats
+// handler if stats tracking enabled, otherwise by Do
+type rpcResults struct {
+	success atomic.Int64
+	failure atomic.Int64
+}
+
+// record count an rpc returned err
+func (r *rpcResults) record(err error) {
+	if err == nil {
+		r.success.Add(1)
+	} else {
+		r.failure.Add(1)
+	}
+}
+
+func (s *rpcStats) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (s *rpcStats) HandleRPC(_ context.Context, rs stats.RPCStats) {
+	switch rs := rs.(type) {
+	case *stats.Begin:
+		if !rs.IsTransparentRetryAttempt {
+			s.rpcs.Add(1)
+		}
+	case *stats.OutPayload:
+		s.sent.Add(int64(rs.WireLength))
+	case *stats.InPayload:
+		s.received.Add(int64(rs.WireLength))
+	case *stats.End:
+		if s.results != nil {
+			s.results.record(rs.Error)
+		}
+	}
+}
+
+func (s *rpcStats) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (s *rpcStats) HandleConn(context.Context, stats.ConnStats) {}
+
+// reset clear the counters
+func (s *rpcStats) reset() {
+	s.rpcs.Store(0)
+	s.sent.Store(0)
+	s.received.Store(0)
+}
+
+// RPCCount return the number of rpcs started on c, 0 if stats tracking is not
+// enabled or c is closed
+func (c *IdleClient) RPCCount() int64 {
+	if c.rpcStats == nil {
+		return 0
+	}
+	return c.rpcStats.rpcs.Load()
+}
+
+// RPCBytes return payload bytes sent and received on c, 0 if stats tracking
+// is not enabled or c is closed
+func (c *IdleClient) RPCBytes() (sent, received int64) {
+	if c.rpcStats == nil {
+		return 0, 0
+	}
+	return c.rpcStats.sent.Load(), c.rpcStats.received.Load()
+}
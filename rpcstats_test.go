@@ -0,0 +1,39 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsTracking(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithStatsTracking())
+
+	c, _ := p.Get()
+	c2, _ := p.Get()
+	for i := 0; i < 3; i++ {
+		if err := checkHealth(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkHealth(c2)
+
+	if n, n2 := c.RPCCount(), c2.RPCCount(); n != 3 || n2 != 1 {
+		t.Fatalf("want 3 and 1 rpcs, got %v and %v", n, n2)
+	}
+	if sent, recv := c.RPCBytes(); sent <= 0 || recv <= 0 {
+		t.Fatalf("want bytes counted, got %v sent and %v received", sent, recv)
+	}
+
+	p.DelErrorClient(c)
+	if n := c.RPCCount(); n != 0 {
+		t.Fatalf("want the count cleared on close, got %v", n)
+	}
+}
+
+func TestStatsTrackingNeedDialOptions(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	if _, err := NewGRpcClientPoolE("bufnet", dial, 1, 0, WithStatsTracking()); err != ERROR_DIAL_OPTIONS_IGNORED {
+		t.Fatalf("want ERROR_DIAL_OPTIONS_IGNORED, got %v", err)
+	}
+}
@@ -0,0 +1,38 @@
+package grpc_pool
+
+import (
+	"time"
+)
+
+// Weight of a new sample in the RTT estimate
+const rttAlpha = 0.2
+
+// PutWithLatency give back c like Put, updating its RTT estimate by d, the
+// latency of an rpc observed on it
+func (p *GRpcClientPool) PutWithLatency(c *IdleClient, d time.Duration) error {
+	if c != nil && d > 0 {
+		c.observeRTT(d)
+	}
+	return p.Put(c)
+}
+
+// observeRTT fold d into the moving average of rtt, the first sample is taken
+// as is
+func (c *IdleClient) observeRTT(d time.Duration) {
+	for {
+		old := c.rtt.Load()
+		rtt := int64(d)
+		if old > 0 {
+			rtt = old + int64(rttAlpha*float64(int64(d)-old))
+		}
+		if c.rtt.CompareAndSwap(old, rtt) {
+			return
+		}
+	}
+}
+
+// RTT return the exponentially weighted moving average of latencies reported
+// by PutWithLatency, 0 if none reported
+func (c *IdleClient) RTT() time.Duration {
+	return time.Duration(c.rtt.Load())
+}
@@ -0,0 +1,28 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPutWithLatency(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	if rtt := c.RTT(); rtt != 0 {
+		t.Fatalf("want no RTT before reported, got %v", rtt)
+	}
+	p.PutWithLatency(c, 10*time.Millisecond)
+	if rtt := c.RTT(); rtt != 10*time.Millisecond {
+		t.Fatalf("want the first sample as is, got %v", rtt)
+	}
+
+	c, _ = p.Get()
+	p.PutWithLatency(c, 20*time.Millisecond)
+	if rtt := c.RTT(); rtt != 12*time.Millisecond {
+		t.Fatalf("want the moving average, got %v", rtt)
+	}
+	if s := p.Stats(); s.Idle != 1 {
+		t.Fatalf("want the client given back, got %+v", s)
+	}
+}
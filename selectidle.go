@@ -0,0 +1,67 @@
+package grpc_pool
+
+import (
+	"math/rand"
+)
+
+// IdleSelect is how Get pick a connection from the idle pool
+type IdleSelect int
+
+const (
+	// Least recently used first, spreading rpcs over connections, the
+	// default
+	SelectFIFO IdleSelect = iota
+	// Most recently used first, so surplus connections stay idle and are
+	// reaped
+	SelectLIFO
+	// Randomly, avoiding patterns some resolvers handle badly
+	SelectRandom
+)
+
+// WithIdleSelect set how Get pick an idle connection, see IdleSelect
+func WithIdleSelect(s IdleSelect) Option {
+	return func(p *GRpcClientPool) {
+		p.idleSelect = s
+	}
+}
+
+// WithPreferFresh make Get pick an idle connection never handed out before
+// others whatever WithIdleSelect, e.g. one dialed by warmup or WithMinIdle,
+// as new connections may route to capacity scaled up lately
+func WithPreferFresh() Option {
+	return func(p *GRpcClientPool) {
+		p.preferFresh = true
+	}
+}
+
+// idleIndex return the index of the idle client to pop, pool MUST NOT be
+// empty, p MUST be locked
+func (p *GRpcClientPool) idleIndex() int {
+	n := p.pool.size()
+	if p.preferFresh {
+		for j := 0; j < n; j++ {
+			if c := p.pool.at(j); c.uses == 0 && !c.poisoned() {
+				return j
+			}
+		}
+	}
+
+	i := 0
+	switch p.idleSelect {
+	case SelectLIFO:
+		i = n - 1
+	case SelectRandom:
+		i = rand.Intn(n)
+	}
+
+	// poisoned ones are picked last, see PutPoisoned
+	if p.poisoning && p.pool.at(i).poisoned() {
+		for j := 0; j < n; j++ {
+			if !p.pool.at(j).poisoned() {
+				return j
+			}
+		}
+	}
+
+	return i
+}
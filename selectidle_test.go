@@ -0,0 +1,87 @@
+package grpc_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// idlePool return a pool selecting idle clients by s, with 4 clients given
+// back in order
+func idlePool(t *testing.T, s IdleSelect) (*GRpcClientPool, []*IdleClient) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithIdleSelect(s))
+
+	var cs []*IdleClient
+	for i := 0; i < 4; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	for _, c := range cs {
+		p.Put(c)
+	}
+	return p, cs
+}
+
+func TestSelectFIFO(t *testing.T) {
+	p, cs := idlePool(t, SelectFIFO)
+	for i := 0; i < 8; i++ {
+		c, _ := p.Get()
+		if c != cs[i%4] {
+			t.Fatalf("want the client idle longest, round %v", i)
+		}
+		p.Put(c)
+	}
+}
+
+func TestSelectLIFO(t *testing.T) {
+	p, cs := idlePool(t, SelectLIFO)
+	for i := 0; i < 8; i++ {
+		c, _ := p.Get()
+		if c != cs[3] {
+			t.Fatalf("want the client given back last, round %v", i)
+		}
+		p.Put(c)
+	}
+}
+
+func TestSelectRandom(t *testing.T) {
+	p, _ := idlePool(t, SelectRandom)
+	seen := make(map[*IdleClient]int)
+	for i := 0; i < 400; i++ {
+		c, _ := p.Get()
+		seen[c]++
+		p.Put(c)
+	}
+
+	if len(seen) != 4 {
+		t.Fatalf("want all clients selected, got %v", len(seen))
+	}
+	for _, n := range seen {
+		if n < 50 {
+			t.Fatalf("want selected evenly, got %v", seen)
+		}
+	}
+	if s := p.Stats(); s.Idle != 4 {
+		t.Fatalf("want all clients pooled, got %+v", s)
+	}
+}
+
+func TestPreferFresh(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithPreferFresh(), WithMinIdle(2))
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	p.Put(a)
+	p.Put(b)
+	if err := p.warmup(context.Background(), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if c, _ := p.Get(); c == a || c == b {
+		t.Fatal("want the client dialed by warmup")
+	}
+	// then as WithIdleSelect
+	if d, _ := p.Get(); d != a {
+		t.Fatal("want the client idle longest")
+	}
+}
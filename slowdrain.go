@@ -0,0 +1,135 @@
+package grpc_pool
+
+import (
+	"math"
+	"time"
+)
+
+// SlowDrain retire the connections existing now gradually, fraction of them
+// every interval, e.g. 0.1 per minute, so they are rebalanced without a
+// thundering herd of dials. Idle ones are closed and checked out ones flagged
+// to be retired when given back, in proportion to their numbers. It stops once
+// the pool is down to target connections, all of them are retired or flagged,
+// or the pool released. Connections dialed since are not affected. Calling it
+// again replace the running drain, a fraction of 0 just stop it.
+func (p *GRpcClientPool) SlowDrain(fraction float64, interval time.Duration, target int) {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.slowDrainStop != nil {
+		close(p.slowDrainStop)
+		p.slowDrainStop = nil
+	}
+	if fraction <= 0 || interval <= 0 || p.released() {
+		return
+	}
+	fraction = math.Min(fraction, 1)
+
+	start := time.Now()
+	step := int(math.Ceil(fraction * float64(p.count)))
+	if step == 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	p.slowDrainStop = stop
+	tick, stopTick := p.ticker(interval)
+	p.background(func() {
+		defer stopTick()
+
+		for {
+			select {
+			case <-p.done:
+				return
+			case <-stop:
+				return
+			case <-tick:
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if !p.drainStep(start, step, target) {
+					p.Lock()
+					if p.slowDrainStop == stop {
+						p.slowDrainStop = nil
+					}
+					p.Unlock()
+					return
+				}
+			}
+		}
+	})
+}
+
+// ticker return a channel ticking every d and a func to stop it
+func (p *GRpcClientPool) ticker(d time.Duration) (<-chan time.Time, func()) {
+	if p.newTicker != nil {
+		return p.newTicker(d)
+	}
+	t := time.NewTicker(d)
+	return t.C, t.Stop
+}
+
+// drainStep retire up to n connections created before start, but keep target
+// ones, return false if none left to retire
+func (p *GRpcClientPool) drainStep(start time.Time, n, target int) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	old := func(c *IdleClient) bool {
+		return !c.createdTime.After(start) && !c.retireOnReturn && !c.closed
+	}
+	var retiring []*IdleClient
+	for _, c := range p.idleClients() {
+		if old(c) {
+			retiring = append(retiring, c)
+		}
+	}
+	idle := len(retiring)
+	var flag []*IdleClient
+	for c := range p.out {
+		if old(c) {
+			flag = append(flag, c)
+		}
+	}
+	out := len(flag)
+	if idle+out == 0 {
+		return false
+	}
+
+	// flagged ones are going away already
+	left := p.count - target
+	for c := range p.out {
+		if c.retireOnReturn || c.ephemeral {
+			left--
+		}
+	}
+	if left <= 0 {
+		return false
+	}
+	if n > left {
+		n = left
+	}
+
+	// split n in proportion, checked out ones take what idle ones can't
+	nOut := n * out / (idle + out)
+	nIdle := n - nOut
+	if nIdle > idle {
+		nOut += nIdle - idle
+		nIdle = idle
+	}
+	if nOut > out {
+		nOut = out
+	}
+	for _, c := range flag[:nOut] {
+		c.retireOnReturn = true
+	}
+
+	for _, c := range retiring[:nIdle] {
+		p.removeIdle(c)
+		p.retire(c)
+	}
+
+	return true
+}
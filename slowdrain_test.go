@@ -0,0 +1,112 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeTicker make the drains of p tick when the func returned called, it
+// ticks the latest drain, and return false if it's not waiting for a tick
+func fakeTicker(p *GRpcClientPool) func() bool {
+	var tick chan time.Time
+	p.Lock()
+	p.newTicker = func(time.Duration) (<-chan time.Time, func()) {
+		tick = make(chan time.Time)
+		return tick, func() {}
+	}
+	p.Unlock()
+
+	return func() bool {
+		p.Lock()
+		c := tick
+		p.Unlock()
+
+		select {
+		case c <- time.Now():
+			return true
+		case <-time.After(50 * time.Millisecond):
+			return false
+		}
+	}
+}
+
+// getClients get n clients from p and give back the first idle of them
+func getClients(p *GRpcClientPool, n, idle int) []*IdleClient {
+	var cs []*IdleClient
+	for i := 0; i < n; i++ {
+		c, _ := p.Get()
+		cs = append(cs, c)
+	}
+	for _, c := range cs[:idle] {
+		p.Put(c)
+	}
+	return cs
+}
+
+func TestSlowDrain(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 20, time.Minute)
+	tick := fakeTicker(p)
+	cs := getClients(p, 10, 8)
+
+	p.SlowDrain(0.2, time.Minute, 0)
+	tick()
+	waitUntil(t, func() bool { return p.Stats().Count == 8 })
+	if s := p.Stats(); s.Idle != 6 {
+		t.Fatalf("want 2 idle clients closed, got %+v", s)
+	}
+
+	// checked out ones are flagged in proportion as idle ones run out
+	for i := 0; i < 4; i++ {
+		tick()
+	}
+	waitUntil(t, func() bool { return p.Stats().Count == 2 })
+	p.Lock()
+	flagged := cs[8].retireOnReturn && cs[9].retireOnReturn
+	p.Unlock()
+	if s := p.Stats(); s.Idle != 0 || !flagged {
+		t.Fatalf("want the checked out clients flagged, got %+v", s)
+	}
+
+	p.Put(cs[8])
+	p.Put(cs[9])
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the flagged clients retired, got %+v", s)
+	}
+}
+
+func TestSlowDrainTarget(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 20, time.Minute)
+	tick := fakeTicker(p)
+	getClients(p, 10, 10)
+
+	p.SlowDrain(0.3, time.Minute, 5)
+	for i := 0; i < 3; i++ {
+		tick()
+	}
+	waitUntil(t, func() bool { return p.Stats().Count == 5 })
+
+	// stopped at the target
+	if tick() {
+		t.Fatal("want the drain stopped")
+	}
+}
+
+func TestSlowDrainStop(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 20, time.Minute)
+	tick := fakeTicker(p)
+	getClients(p, 10, 10)
+
+	// replaced, then stopped by a fraction of 0
+	p.SlowDrain(0.5, time.Minute, 0)
+	p.SlowDrain(0.2, time.Minute, 0)
+	tick()
+	waitUntil(t, func() bool { return p.Stats().Count == 8 })
+	p.SlowDrain(0, time.Minute, 0)
+
+	// it may still take the tick, but retire none
+	tick()
+	time.Sleep(20 * time.Millisecond)
+	if s := p.Stats(); s.Count != 8 {
+		t.Fatalf("want no more retired once stopped, got %+v", s)
+	}
+}
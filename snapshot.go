@@ -0,0 +1,61 @@
+package grpc_pool
+
+import (
+	"sort"
+)
+
+// Snapshot return the sorted addresses of all pools and of per-address
+// overrides (SetDialFunc, SetDialOptions, SetPriority), which with
+// WithOverridesFrom rebuild an equivalent MapPool, e.g. to swap in new
+// defaults with no downtime:
+//
+//	next := NewMapPool(dial, maxCount, idleTimeout, WithOverridesFrom(old))
+//	for _, addr := range old.Snapshot() {
+//		if p, ok := old.GetPoolExists(addr); ok {
+//			p.TransferTo(next.GetPool(addr))
+//		}
+//	}
+//	// swap next in, e.g. by atomic.Pointer, then drain the old one
+//	old.ReleaseAllPool()
+//
+// Connections checked out of old when transferred are closed when given
+// back, as their pools are released.
+func (mp *MapPool) Snapshot() []string {
+	mp.RLock()
+	seen := make(map[string]struct{}, len(mp.pools))
+	for key := range mp.pools {
+		seen[key.addr] = struct{}{}
+	}
+	for addr := range mp.dialFs {
+		seen[addr] = struct{}{}
+	}
+	for addr := range mp.priorities {
+		seen[addr] = struct{}{}
+	}
+	mp.RUnlock()
+
+	addrs := make([]string, 0, len(seen))
+	for addr := range seen {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	return addrs
+}
+
+// WithOverridesFrom copy the per-address overrides of src, dial functions and
+// priorities, to the MapPool created. Pools and their connections are not
+// copied, see Snapshot.
+func WithOverridesFrom(src *MapPool) MapOption {
+	return func(mp *MapPool) {
+		src.RLock()
+		defer src.RUnlock()
+
+		for addr, dialF := range src.dialFs {
+			mp.dialFs[addr] = dialF
+		}
+		for addr, priority := range src.priorities {
+			mp.priorities[addr] = priority
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package grpc_pool
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSnapshotSwap(t *testing.T) {
+	dial := newTestServer(t).DialFunc()
+	old := NewMapPool(dial, 5, time.Minute)
+	old.SetPriority("x", 3)
+	for _, addr := range []string{"b", "a"} {
+		p := old.GetPool(addr)
+		c, err := p.Get()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p.Put(c)
+	}
+
+	snap := old.Snapshot()
+	if !reflect.DeepEqual(snap, []string{"a", "b", "x"}) {
+		t.Fatalf("want pools and overrides sorted, got %v", snap)
+	}
+
+	next := NewMapPool(dial, 5, time.Minute, WithOverridesFrom(old))
+	defer next.ReleaseAllPool()
+	if pri := next.priorities["x"]; pri != 3 {
+		t.Fatalf("want the priority copied, got %v", pri)
+	}
+	moved := 0
+	for _, addr := range snap {
+		if p, ok := old.GetPoolExists(addr); ok {
+			moved += p.TransferTo(next.GetPool(addr))
+		}
+	}
+	old.ReleaseAllPool()
+
+	if moved != 2 {
+		t.Fatalf("want 2 warm connections moved, got %v", moved)
+	}
+	c, _ := next.GetPool("a").Get()
+	if err := checkHealth(c); err != nil {
+		t.Fatalf("want the moved connection usable after the old released, got %v", err)
+	}
+}
@@ -0,0 +1,217 @@
+package grpc_pool
+
+import (
+	"sort"
+	"time"
+)
+
+// Stats is a snapshot of the state of GRpcClientPool
+type Stats struct {
+	// Valid conn num in pool, both idle and in use
+	Count int
+	// Idle conn num in pool
+	Idle int
+	// Conn num taken by Get and not given back yet
+	InUse int
+	// Max size of pool
+	MaxCount int
+	// Ephemeral conn num dialed beyond MaxCount, counted in Count, see
+	// WithOverflow
+	Overflow int
+
+	// Average age of current connections, idle and in use
+	AvgConnAge time.Duration
+	// Average times current connections have been taken by Get again, the
+	// first checkout after dialed is not a reuse
+	AvgReuses float64
+
+	// How warm the pool is in [0,1], 1 means every connection is idle and
+	// 0 means none is, the pool is exhausted or has no connection yet
+	WarmScore float64
+
+	// Cumulative counters since the pool created: connections dialed, dials
+	// failed, connections closed as idle timeout or outlived, and Gets served
+	// by idle connections
+	Dials      int64
+	DialErrors int64
+	Timeouts   int64
+	Reuses     int64
+	// Cumulative ephemeral connections dialed, see WithOverflow
+	OverflowDials int64
+
+	// Cumulative rpcs succeeded and failed, counted by Do, or of all rpcs on
+	// the connections if WithStatsTracking set
+	RPCSuccess int64
+	RPCFailure int64
+
+	// Latency percentiles of successful Gets in recent one or two minutes
+	GetLatency LatencyPercentiles
+
+	// Dialing stopped as file descriptors exhausted, until a connection
+	// closed or a probe dial succeeded
+	FDExhausted bool
+
+	// Connections per replica, nil unless the pool created by
+	// NewGRpcClientPoolMulti
+	Addrs map[string]int
+
+	// Time the snapshot taken
+	Time time.Time
+}
+
+// StatsDelta is the difference of counters between two Stats
+type StatsDelta struct {
+	// Time between the two snapshots, 0 if either has no Time
+	Elapsed time.Duration
+
+	Dials      int64
+	DialErrors int64
+	Timeouts   int64
+	Reuses     int64
+	RPCSuccess int64
+	RPCFailure int64
+
+	OverflowDials int64
+}
+
+// Sub return the counters of s minus those of an earlier snapshot b, so rates
+// can be derived by sampling Stats periodically
+func (s Stats) Sub(b Stats) StatsDelta {
+	d := StatsDelta{
+		Dials:      s.Dials - b.Dials,
+		DialErrors: s.DialErrors - b.DialErrors,
+		Timeouts:   s.Timeouts - b.Timeouts,
+		Reuses:     s.Reuses - b.Reuses,
+		RPCSuccess: s.RPCSuccess - b.RPCSuccess,
+		RPCFailure: s.RPCFailure - b.RPCFailure,
+
+		OverflowDials: s.OverflowDials - b.OverflowDials,
+	}
+	if !s.Time.IsZero() && !b.Time.IsZero() {
+		d.Elapsed = s.Time.Sub(b.Time)
+	}
+
+	return d
+}
+
+// Rate return n per second over Elapsed, 0 if Elapsed unknown
+func (d StatsDelta) Rate(n int64) float64 {
+	if d.Elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / d.Elapsed.Seconds()
+}
+
+// Stats return a snapshot of the pool
+func (p *GRpcClientPool) Stats() Stats {
+	p.Lock()
+	defer p.Unlock()
+
+	now := time.Now()
+	s := Stats{
+		Count:    p.count,
+		Idle:     p.pool.size(),
+		InUse:    len(p.out),
+		MaxCount: p.maxCount,
+		Overflow: p.overflow,
+
+		Dials:      p.dials,
+		DialErrors: p.dialErrors,
+		Timeouts:   p.timeouts,
+		Reuses:     p.reuses,
+		RPCSuccess: p.rpcResults.success.Load(),
+		RPCFailure: p.rpcResults.failure.Load(),
+
+		OverflowDials: p.overflowDials,
+
+		FDExhausted: p.fdExhausted.Load(),
+
+		Time: now,
+	}
+
+	var (
+		n      int
+		age    time.Duration
+		reuses int
+	)
+	if len(p.addrs) > 0 {
+		s.Addrs = make(map[string]int, len(p.addrs))
+		for _, addr := range p.addrs {
+			s.Addrs[addr] = 0
+		}
+	}
+	add := func(c *IdleClient) {
+		n++
+		age += now.Sub(c.createdTime)
+		if c.uses > 1 {
+			reuses += c.uses - 1
+		}
+		if s.Addrs != nil {
+			s.Addrs[c.addr]++
+		}
+	}
+	for i := 0; i < p.pool.size(); i++ {
+		add(p.pool.at(i))
+	}
+	for c := range p.out {
+		add(c)
+	}
+	if n > 0 {
+		s.AvgConnAge = age / time.Duration(n)
+		s.AvgReuses = float64(reuses) / float64(n)
+	}
+	s.WarmScore = warmScore(s.Idle, s.Count, s.MaxCount)
+	s.GetLatency = p.getLatency.percentiles(now)
+
+	return s
+}
+
+// warmScore is the ratio of idle connections, a pool reached maxCount
+// without idle connection is exhausted
+func warmScore(idle, count, maxCount int) float64 {
+	if idle <= 0 || count <= 0 {
+		return 0
+	}
+	if maxCount > 0 && count > maxCount {
+		count = maxCount
+	}
+	if idle >= count {
+		return 1
+	}
+
+	return float64(idle) / float64(count)
+}
+
+// OutstandingConn is a connection taken by Get and not given back yet
+type OutstandingConn struct {
+	Client *IdleClient
+	// Time taken by Get, and how long it has been held
+	CheckoutTime time.Time
+	Held         time.Duration
+	// Copy of the tags of Client
+	Tags map[string]string
+}
+
+// Outstanding return connections checked out now, longest held first, to find
+// callers holding connections too long
+func (p *GRpcClientPool) Outstanding() []OutstandingConn {
+	p.Lock()
+	now := time.Now()
+	conns := make([]OutstandingConn, 0, len(p.out))
+	for c := range p.out {
+		conns = append(conns, OutstandingConn{
+			Client:       c,
+			CheckoutTime: c.checkoutTime,
+			Held:         now.Sub(c.checkoutTime),
+		})
+	}
+	p.Unlock()
+
+	for i := range conns {
+		conns[i].Tags = conns[i].Client.Tags()
+	}
+	sort.Slice(conns, func(i, j int) bool {
+		return conns[i].CheckoutTime.Before(conns[j].CheckoutTime)
+	})
+	return conns
+}
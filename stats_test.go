@@ -0,0 +1,205 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestStatsAgeAndReuses(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	now := time.Now()
+	p.Lock()
+	a.createdTime = now.Add(-10 * time.Second)
+	b.createdTime = now.Add(-20 * time.Second)
+	a.uses, b.uses = 1, 3
+	p.Unlock()
+
+	s := p.Stats()
+	if s.Count != 2 || s.InUse != 2 {
+		t.Fatalf("want 2 in use, got %+v", s)
+	}
+	// the first use is not a reuse
+	if s.AvgReuses != 1 {
+		t.Fatalf("want 1 reuse on average, got %v", s.AvgReuses)
+	}
+	if s.AvgConnAge < 15*time.Second || s.AvgConnAge > 16*time.Second {
+		t.Fatalf("want about 15s on average, got %v", s.AvgConnAge)
+	}
+}
+
+func TestStatsReusedOnce(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	if s := p.Stats(); s.AvgReuses != 0 {
+		t.Fatalf("want no reuse of a client dialed, got %v", s.AvgReuses)
+	}
+	p.Put(c)
+	p.Get()
+	if s := p.Stats(); s.AvgReuses != 1 {
+		t.Fatalf("want 1 reuse, got %v", s.AvgReuses)
+	}
+}
+
+func TestStatsEmptyPool(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	if s := p.Stats(); s.AvgConnAge != 0 || s.AvgReuses != 0 {
+		t.Fatalf("want no averages, got %+v", s)
+	}
+}
+
+func TestWarmScoreExtremes(t *testing.T) {
+	for _, tt := range []struct {
+		idle, count, maxCount int
+		want                  float64
+	}{
+		{0, 0, 5, 0}, // nothing dialed
+		{0, 5, 5, 0}, // exhausted
+		{5, 5, 5, 1}, // all idle
+		{3, 3, 0, 1}, // no limit, all idle
+		{1, 4, 4, 0.25},
+		{2, 6, 4, 0.5}, // boosted over maxCount
+	} {
+		if got := warmScore(tt.idle, tt.count, tt.maxCount); got != tt.want {
+			t.Errorf("warmScore(%v, %v, %v) = %v, want %v", tt.idle, tt.count, tt.maxCount, got, tt.want)
+		}
+	}
+}
+
+func TestStatsWarmScore(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	if s := p.Stats(); s.WarmScore != 0 {
+		t.Fatalf("want exhausted, got %v", s.WarmScore)
+	}
+	p.Put(a)
+	p.Put(b)
+	if s := p.Stats(); s.WarmScore != 1 {
+		t.Fatalf("want warm, got %v", s.WarmScore)
+	}
+}
+
+func TestOutstanding(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	a.SetTag("caller", "a")
+	time.Sleep(5 * time.Millisecond)
+	b, _ := p.Get()
+	c, _ := p.Get()
+	p.Put(c)
+
+	o := p.Outstanding()
+	if len(o) != 2 {
+		t.Fatalf("want 2 outstanding, got %+v", o)
+	}
+	if o[0].Client != a || o[1].Client != b {
+		t.Fatalf("want the longest held first, got %+v", o)
+	}
+	if o[0].Tags["caller"] != "a" || o[0].Held < o[1].Held || o[0].Held < 5*time.Millisecond {
+		t.Fatalf("want the tags and hold time of a, got %+v", o[0])
+	}
+
+	p.Put(a)
+	p.Put(b)
+	if o := p.Outstanding(); len(o) != 0 {
+		t.Fatalf("want none outstanding, got %+v", o)
+	}
+}
+
+func TestStatsSub(t *testing.T) {
+	now := time.Now()
+	a := Stats{Dials: 2, DialErrors: 1, Timeouts: 3, Reuses: 10, RPCSuccess: 5, RPCFailure: 1, Time: now}
+	b := Stats{Dials: 6, DialErrors: 1, Timeouts: 4, Reuses: 30, RPCSuccess: 9, RPCFailure: 3, Time: now.Add(2 * time.Second)}
+
+	d := b.Sub(a)
+	want := StatsDelta{Elapsed: 2 * time.Second, Dials: 4, Timeouts: 1, Reuses: 20, RPCSuccess: 4, RPCFailure: 2}
+	if d != want {
+		t.Fatalf("want %+v, got %+v", want, d)
+	}
+	if r := d.Rate(d.Reuses); r != 10 {
+		t.Fatalf("want 10 reuses per second, got %v", r)
+	}
+
+	if d := (Stats{Dials: 3}).Sub(Stats{}); d.Elapsed != 0 || d.Rate(d.Dials) != 0 {
+		t.Fatalf("want no rate without Time, got %+v", d)
+	}
+}
+
+func TestStatsCounters(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, 50*time.Millisecond)
+
+	a := p.Stats()
+	c1, _ := p.Get()
+	c2, _ := p.Get()
+	p.Put(c1)
+	p.Put(c2)
+	c1, _ = p.Get()
+	p.Put(c1)
+	time.Sleep(60 * time.Millisecond)
+	c1, _ = p.Get()
+	p.Put(c1)
+
+	d := p.Stats().Sub(a)
+	if d.Dials != 3 || d.Reuses != 1 || d.Timeouts != 2 || d.Elapsed <= 0 {
+		t.Fatalf("want 3 dials, 1 reuse and 2 timeouts, got %+v", d)
+	}
+
+	bad := NewGRpcClientPool("bufnet", func(string) (*grpc.ClientConn, error) {
+		return nil, errors.New("dial")
+	}, 5, time.Minute)
+	defer bad.Release()
+	bad.Get()
+	if s := bad.Stats(); s.DialErrors != 1 {
+		t.Fatalf("want the dial error counted, got %+v", s)
+	}
+}
+
+func TestMapPoolRPCResults(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 5, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		mp.GetPool("good").Do(ctx, func(*grpc.ClientConn) error { return nil })
+		mp.GetPool("bad").Do(ctx, func(*grpc.ClientConn) error { return errors.New("rpc") })
+	}
+
+	for _, s := range mp.Stats() {
+		switch {
+		case s.Addr == "good" && (s.RPCSuccess != 3 || s.RPCFailure != 0),
+			s.Addr == "bad" && (s.RPCSuccess != 0 || s.RPCFailure != 3):
+			t.Errorf("want the rpc results of %v, got %+v", s.Addr, s.Stats)
+		}
+	}
+}
+
+func TestRPCResultsByStatsHandler(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithStatsTracking())
+
+	ctx := context.Background()
+	// counted by the stats handler once each, not by Do
+	p.Do(ctx, func(conn *grpc.ClientConn) error {
+		conn.Invoke(ctx, "/unknown.Service/Method", nil, nil)
+		return conn.Invoke(ctx, "/unknown.Service/Method", nil, nil)
+	})
+	c, _ := p.Get()
+	checkHealth(c)
+	p.Put(c)
+
+	waitUntil(t, func() bool {
+		s := p.Stats()
+		return s.RPCFailure == 2 && s.RPCSuccess == 1
+	})
+}
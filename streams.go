@@ -0,0 +1,100 @@
+package grpc_pool
+
+import (
+	"context"
+)
+
+// Default streams opened on a connection by GetForStream, the usual HTTP/2
+// max concurrent streams of servers
+const defaultStreamsPerConn = 100
+
+// WithStreamsPerConn set the max streams GetForStream open on a connection,
+// it should not exceed the max concurrent streams of the server
+func WithStreamsPerConn(n int) Option {
+	return func(p *GRpcClientPool) {
+		if n > 0 {
+			p.streamsPerConn = n
+		}
+	}
+}
+
+// GetForStream return a connection to open a stream on, shared with other
+// streams got by GetForStream. A connection with stream budget left is
+// picked, a new one is got by GetContext when all are saturated. Call
+// PutStream when the stream done, the connection is given back to the pool
+// after its last stream done. No connection is shared while the pool is
+// paused, draining or closed, GetContext decides then.
+func (p *GRpcClientPool) GetForStream(ctx context.Context) (*IdleClient, error) {
+	p.Lock()
+	if p.canShare() {
+		for c := range p.out {
+			if c.acquireStream(p.maxStreams()) {
+				p.Unlock()
+				return c, nil
+			}
+		}
+	}
+	p.Unlock()
+
+	c, err := p.GetContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	p.Lock()
+	c.streams = 1
+	p.Unlock()
+
+	return c, nil
+}
+
+// PutStream release a stream of c got by GetForStream, c is given back by Put
+// once it has no stream
+func (p *GRpcClientPool) PutStream(c *IdleClient) error {
+	if c == nil {
+		return ERROR_NIL_CLIENT
+	}
+
+	p.Lock()
+	if !c.releaseStream() {
+		p.Unlock()
+		return nil
+	}
+	p.Unlock()
+
+	return p.Put(c)
+}
+
+// canShare return true if connections out can take more streams, p MUST be
+// locked
+func (p *GRpcClientPool) canShare() bool {
+	return !p.paused && !p.draining && !p.closing.Load() && !p.released()
+}
+
+// maxStreams return the stream budget of a connection, p MUST be locked
+func (p *GRpcClientPool) maxStreams() int {
+	if p.streamsPerConn > 0 {
+		return p.streamsPerConn
+	}
+	return defaultStreamsPerConn
+}
+
+// acquireStream open a stream on c if it's shared by streams and has budget
+// left, connections to be retired take no more, p MUST be locked
+func (c *IdleClient) acquireStream(max int) bool {
+	if c.streams == 0 || c.streams >= max || c.closed || c.retireOnReturn {
+		return false
+	}
+	c.streams++
+	return true
+}
+
+// releaseStream close a stream on c, return true if it was the last, p MUST
+// be locked
+func (c *IdleClient) releaseStream() bool {
+	if c.streams == 0 {
+		return false
+	}
+	c.streams--
+	return c.streams == 0
+}
@@ -0,0 +1,56 @@
+package grpc_pool
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetForStream(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute, WithStreamsPerConn(2))
+	ctx := context.Background()
+
+	a, _ := p.GetForStream(ctx)
+	b, _ := p.GetForStream(ctx)
+	c, err := p.GetForStream(ctx)
+	if err != nil || a != b || c == a {
+		t.Fatalf("want a new client once a saturated, got %v", err)
+	}
+	if d, _ := p.GetForStream(ctx); d != c {
+		t.Fatal("want c shared till saturated")
+	}
+	if _, err := p.GetForStream(ctx); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+
+	// idle once no streams left
+	p.PutStream(a)
+	if s := p.Stats(); s.Idle != 0 {
+		t.Fatalf("want a kept out for the stream left, got %+v", s)
+	}
+	p.PutStream(b)
+	if s := p.Stats(); s.Idle != 1 {
+		t.Fatalf("want a idle, got %+v", s)
+	}
+	if e, _ := p.GetForStream(ctx); e != a {
+		t.Fatal("want a with budget")
+	}
+}
+
+func TestGetForStreamPausedOrClosed(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 2, time.Minute)
+	ctx := context.Background()
+
+	if _, err := p.GetForStream(ctx); err != nil {
+		t.Fatal(err)
+	}
+	p.Pause()
+	if _, err := p.GetForStream(ctx); err != ERROR_POOL_PAUSED {
+		t.Fatalf("want ERROR_POOL_PAUSED, got %v", err)
+	}
+	p.Resume()
+	p.Release()
+	if _, err := p.GetForStream(ctx); err != ERROR_POOL_CLOSED {
+		t.Fatalf("want ERROR_POOL_CLOSED, got %v", err)
+	}
+}
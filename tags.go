@@ -0,0 +1,114 @@
+package grpc_pool
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"google.golang.org/grpc/stats"
+)
+
+// Tags set by the pool
+const (
+	// Remote address the connection connected to, see WithCapturePeer
+	TagPeer = "peer"
+)
+
+// Tag return the value of tag key, or "" if not set. A tag set by
+// SetCheckoutTag shadow the one set by SetTag.
+func (c *IdleClient) Tag(key string) string {
+	c.tagsMu.RLock()
+	defer c.tagsMu.RUnlock()
+
+	if v, ok := c.checkoutTags[key]; ok {
+		return v
+	}
+	return c.tags[key]
+}
+
+// SetTag label the connection with key and value
+func (c *IdleClient) SetTag(key, value string) {
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+
+	if c.tags == nil {
+		c.tags = make(map[string]string)
+	}
+	c.tags[key] = value
+}
+
+// SetCheckoutTag label the connection with key and value until it's given
+// back, e.g. for the request holding it
+func (c *IdleClient) SetCheckoutTag(key, value string) {
+	c.tagsMu.Lock()
+	defer c.tagsMu.Unlock()
+
+	if c.checkoutTags == nil {
+		c.checkoutTags = make(map[string]string)
+	}
+	c.checkoutTags[key] = value
+}
+
+// Tags return a copy of all tags of the connection, checkout tags included
+func (c *IdleClient) Tags() map[string]string {
+	c.tagsMu.RLock()
+	defer c.tagsMu.RUnlock()
+
+	tags := make(map[string]string, len(c.tags)+len(c.checkoutTags))
+	for k, v := range c.tags {
+		tags[k] = v
+	}
+	for k, v := range c.checkoutTags {
+		tags[k] = v
+	}
+	return tags
+}
+
+// resetCheckout clear the data of the last checkout, p MUST be locked
+func (c *IdleClient) resetCheckout() {
+	c.checkoutTime = time.Time{}
+
+	c.tagsMu.Lock()
+	c.checkoutTags = nil
+	c.tagsMu.Unlock()
+}
+
+// Peer return the remote address captured once connected, see WithCapturePeer
+func (c *IdleClient) Peer() string {
+	return c.Tag(TagPeer)
+}
+
+// peerCapture is the stats.Handler of one connection tagging it with the
+// remote address of each transport connected, see WithCapturePeer
+type peerCapture struct {
+	c    atomic.Pointer[IdleClient]
+	addr atomic.Pointer[string]
+}
+
+// attach tag c with the address connected before it's created, if any
+func (h *peerCapture) attach(c *IdleClient) {
+	h.c.Store(c)
+	if addr := h.addr.Load(); addr != nil {
+		c.SetTag(TagPeer, *addr)
+	}
+}
+
+func (h *peerCapture) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	if info.RemoteAddr == nil {
+		return ctx
+	}
+	addr := info.RemoteAddr.String()
+	h.addr.Store(&addr)
+	if c := h.c.Load(); c != nil {
+		c.SetTag(TagPeer, addr)
+	}
+	return ctx
+}
+
+func (h *peerCapture) HandleConn(context.Context, stats.ConnStats) {}
+
+func (h *peerCapture) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+func (h *peerCapture) HandleRPC(context.Context, stats.RPCStats) {}
@@ -0,0 +1,50 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCapturePeer(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithCapturePeer(), WithDialOptionsFunc(srv.DialOptionsFunc()))
+
+	c, _ := p.Get()
+	if peer := c.Peer(); peer != "" {
+		t.Fatalf("want no peer before connected, got %v", peer)
+	}
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	if peer := c.Peer(); peer != "bufconn" {
+		t.Fatalf("want the remote address of bufconn, got %q", peer)
+	}
+	if c.Tag(TagPeer) != c.Peer() {
+		t.Fatal("want the peer kept as tag")
+	}
+}
+
+func TestCapturePeerNeedDialOptions(t *testing.T) {
+	srv := newTestServer(t)
+	if _, err := NewGRpcClientPoolE("bufnet", srv.DialFunc(), 5, time.Minute, WithCapturePeer()); err != ERROR_DIAL_OPTIONS_IGNORED {
+		t.Fatalf("want ERROR_DIAL_OPTIONS_IGNORED, got %v", err)
+	}
+}
+
+func TestCheckoutTagsReset(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	c, _ := p.Get()
+	c.SetTag("a", "1")
+	c.SetCheckoutTag("req", "x")
+	c.SetCheckoutTag("a", "2")
+	if c.Tag("a") != "2" || len(c.Tags()) != 2 || c.checkoutTime.IsZero() {
+		t.Fatalf("want checkout tags over tags, got %v", c.Tags())
+	}
+
+	// the RTT estimate persists
+	p.PutWithLatency(c, time.Millisecond)
+	if c.Tag("a") != "1" || c.Tag("req") != "" || !c.checkoutTime.IsZero() || c.RTT() != time.Millisecond {
+		t.Fatalf("want checkout data reset, got %v", c.Tags())
+	}
+}
@@ -0,0 +1,61 @@
+package testutil_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool"
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+// greeter answer SayHello with a greeting
+type greeter struct{}
+
+func (greeter) SayHello(_ context.Context, in *wrapperspb.StringValue) (*wrapperspb.StringValue, error) {
+	return wrapperspb.String("Hello " + in.GetValue()), nil
+}
+
+// greeterDesc describe the Greeter service as protoc would generate, with
+// wrappers as messages
+var greeterDesc = grpc.ServiceDesc{
+	ServiceName: "helloworld.Greeter",
+	HandlerType: (*interface{})(nil),
+	Methods: []grpc.MethodDesc{{
+		MethodName: "SayHello",
+		Handler: func(srv interface{}, ctx context.Context, dec func(interface{}) error, _ grpc.UnaryServerInterceptor) (interface{}, error) {
+			in := new(wrapperspb.StringValue)
+			if err := dec(in); err != nil {
+				return nil, err
+			}
+			return srv.(greeter).SayHello(ctx, in)
+		},
+	}},
+}
+
+func Example() {
+	srv := testutil.NewServer(func(s *grpc.Server) {
+		s.RegisterService(&greeterDesc, greeter{})
+	})
+	defer srv.Stop()
+
+	pool := grpc_pool.NewGRpcClientPool("bufnet", srv.DialFunc(), 5, time.Second*10)
+	defer pool.Release()
+
+	c, err := pool.Get()
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	defer pool.Put(c)
+
+	r := new(wrapperspb.StringValue)
+	if err := c.GetConn().Invoke(context.Background(), "/helloworld.Greeter/SayHello", wrapperspb.String("SongLiangChen"), r); err != nil {
+		fmt.Println(err)
+		return
+	}
+	fmt.Println(r.GetValue())
+	// Output: Hello SongLiangChen
+}
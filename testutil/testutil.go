@@ -0,0 +1,86 @@
+// Package testutil provides an in-memory gRPC server, so code using
+// grpc_pool can be tested without opening sockets.
+//
+// FOR EXAMPLE:
+//
+//	srv := testutil.NewServer(func(s *grpc.Server) {
+//		RegisterGreeterServer(s, &Server{})
+//	})
+//	defer srv.Stop()
+//
+//	pool := grpc_pool.NewGRpcClientPool("bufnet", srv.DialFunc(), 5, time.Second*10)
+//	defer pool.Release()
+//
+//	c, err := pool.Get()
+//	if err != nil {
+//		return err
+//	}
+//	r, err := NewGreeterClient(c.GetConn()).SayHello(ctx, &HelloRequest{Name: "SongLiangChen"})
+package testutil
+
+import (
+	"context"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// Size of the in-memory listener buffer
+const bufSize = 1024 * 1024
+
+// Server is a gRPC server serving over an in-memory listener
+type Server struct {
+	*grpc.Server
+
+	lis *bufconn.Listener
+}
+
+// NewServer start serving a gRPC server in background, register is called
+// to register services before serving
+func NewServer(register func(*grpc.Server), opts ...grpc.ServerOption) *Server {
+	s := &Server{
+		Server: grpc.NewServer(opts...),
+		lis:    bufconn.Listen(bufSize),
+	}
+	if register != nil {
+		register(s.Server)
+	}
+
+	go s.Serve(s.lis)
+
+	return s
+}
+
+// DialContext connect to the server over the in-memory listener
+func (s *Server) DialContext(ctx context.Context, _ string) (net.Conn, error) {
+	return s.lis.DialContext(ctx)
+}
+
+// DialFunc return a grpc_pool.DialFunc connect to the server, whatever
+// address the pool has
+func (s *Server) DialFunc(opts ...grpc.DialOption) func(string) (*grpc.ClientConn, error) {
+	opts = append([]grpc.DialOption{
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(s.DialContext),
+	}, opts...)
+
+	return func(addr string) (*grpc.ClientConn, error) {
+		return grpc.NewClient("passthrough:///"+addr, opts...)
+	}
+}
+
+// DialOptionsFunc return a grpc_pool.DialOptionsFunc connect to the server,
+// for pools with dial options, e.g. WithStatsTracking
+func (s *Server) DialOptionsFunc() func(string, ...grpc.DialOption) (*grpc.ClientConn, error) {
+	return func(addr string, opts ...grpc.DialOption) (*grpc.ClientConn, error) {
+		return s.DialFunc(opts...)(addr)
+	}
+}
+
+// Stop stop the server and close the listener
+func (s *Server) Stop() {
+	s.Server.Stop()
+	s.lis.Close()
+}
@@ -0,0 +1,73 @@
+package grpc_pool
+
+// TransferTo move idle connections dialed to an address of dst into dst, e.g.
+// to keep warm connections on live reconfiguration, and return how many
+// moved. Connections of other addresses, or beyond the limits of dst, stay
+// in p. Moved connections are watched by dst if it has WithStateWatching.
+func (p *GRpcClientPool) TransferTo(dst *GRpcClientPool) int {
+	if dst == p {
+		return 0
+	}
+
+	// the pools are never locked together, so transferring both ways at
+	// the same time can't deadlock
+	p.Lock()
+	var moving []*IdleClient
+	for _, c := range p.idleClients() {
+		if dst.dialsTo(c.addr) {
+			p.removeIdle(c)
+			p.stopWatch(c)
+			moving = append(moving, c)
+		}
+	}
+	p.addCount(-len(moving))
+	p.Unlock()
+
+	dst.Lock()
+	moved := 0
+	for _, c := range moving {
+		if dst.released() || dst.closing.Load() || dst.draining || (dst.count >= dst.maxCount && dst.maxCount > 0) ||
+			(dst.maxIdle > 0 && dst.pool.size() >= dst.maxIdle) {
+			break
+		}
+		dst.addCount(1)
+		c.owner = dst
+		c.updateLastCalledTime()
+		dst.addIdle(c)
+		if dst.watchState {
+			dst.startWatch(c)
+		}
+		moved++
+	}
+	dst.Unlock()
+
+	p.Lock()
+	for _, c := range moving[moved:] {
+		// a closing pool keeps no idle conn
+		if p.released() || p.closing.Load() {
+			p.closeClient(c)
+			continue
+		}
+		p.addCount(1)
+		p.addIdle(c)
+		if p.watchState {
+			p.startWatch(c)
+		}
+	}
+	p.Unlock()
+
+	return moved
+}
+
+// dialsTo report whether p dial addr
+func (p *GRpcClientPool) dialsTo(addr string) bool {
+	if len(p.addrs) == 0 {
+		return addr == p.addr
+	}
+	for _, a := range p.addrs {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
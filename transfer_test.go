@@ -0,0 +1,78 @@
+package grpc_pool
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTransferTo(t *testing.T) {
+	srv := newTestServer(t)
+	src, err := NewGRpcClientPoolMulti([]string{"a", "b"}, srv.DialFunc(), 0, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer src.Release()
+	var cs []*IdleClient
+	for i := 0; i < 6; i++ {
+		c, _ := src.Get()
+		cs = append(cs, c)
+	}
+	src.PutAll(cs)
+
+	dst, err := NewGRpcClientPoolE("a", srv.DialFunc(), 2, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer dst.Release()
+	if n := dst.TransferTo(src); n != 0 {
+		t.Fatalf("want nothing to move from an empty pool, got %v", n)
+	}
+
+	// limited by maxCount of dst
+	if n := src.TransferTo(dst); n != 2 {
+		t.Fatalf("want 2 moved, got %v", n)
+	}
+	if s, d := src.Stats(), dst.Stats(); s.Count != 4 || s.Idle != 4 || d.Count != 2 || d.Idle != 2 {
+		t.Fatalf("want counts adjusted, got src %+v and dst %+v", s, d)
+	}
+	c, _ := dst.Get()
+	if err := dst.Put(c); err != nil || c.owner != dst {
+		t.Fatalf("want the moved client owned by dst, got %v", err)
+	}
+
+	// only connections to b move to a pool of b
+	dst2, _ := NewGRpcClientPoolE("b", srv.DialFunc(), 0, time.Minute)
+	defer dst2.Release()
+	if n := src.TransferTo(dst2); n != 3 {
+		t.Fatalf("want the 3 clients of b moved, got %v", n)
+	}
+	if s := src.Stats(); s.Count != 1 {
+		t.Fatalf("want the client of a left, got %+v", s)
+	}
+}
+
+func TestTransferToStateWatching(t *testing.T) {
+	srv := newTestServer(t)
+	src := newTestPool(t, srv, 5, time.Minute, WithStateWatching())
+	dst := newTestPool(t, srv, 5, time.Minute, WithStateWatching())
+
+	c, _ := src.Get()
+	if err := checkHealth(c); err != nil {
+		t.Fatal(err)
+	}
+	src.Put(c)
+	if n := src.TransferTo(dst); n != 1 {
+		t.Fatalf("want 1 moved, got %v", n)
+	}
+
+	// the watcher of src is gone with it, dst watch the moved client
+	src.Release()
+	srv.GracefulStop()
+	waitUntil(t, func() bool { return dst.Stats().Idle == 0 })
+	dst.Lock()
+	closed := c.closed
+	dst.Unlock()
+	if !closed {
+		t.Fatal("want the moved client drained by dst")
+	}
+}
@@ -0,0 +1,110 @@
+package grpc_pool
+
+import (
+	"context"
+	"time"
+)
+
+// WithMaxGetBlock make GetWait block at most d even if ctx has a longer or no
+// deadline, ERROR_GET_TIMEOUT is returned then
+func WithMaxGetBlock(d time.Duration) Option {
+	return func(p *GRpcClientPool) {
+		p.maxGetBlock = d
+	}
+}
+
+// GetWait is like GetContext, but wait for a connection given back or retired
+// rather than return ERROR_MAX_CLIENT_COUNT when the pool is full, until ctx
+// done or the max duration set by WithMaxGetBlock passed.
+func (p *GRpcClientPool) GetWait(ctx context.Context) (*IdleClient, error) {
+	if p.maxGetBlock > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeoutCause(ctx, p.maxGetBlock, ERROR_GET_TIMEOUT)
+		defer cancel()
+	}
+
+	for {
+		c, err := p.GetContext(ctx)
+		if err == ERROR_MAX_CLIENT_COUNT {
+			p.Lock()
+			err = p.waitRoom(ctx)
+			p.Unlock()
+			if err == nil {
+				continue
+			}
+		}
+		if err != nil && context.Cause(ctx) == ERROR_GET_TIMEOUT {
+			err = ERROR_GET_TIMEOUT
+		}
+
+		return c, err
+	}
+}
+
+// waitRoom block until the pool has an idle conn or room to dial, or ctx
+// done, p MUST be locked
+func (p *GRpcClientPool) waitRoom(ctx context.Context) error {
+	if !p.hasRoom() {
+		if err := p.shed(); err != nil {
+			return err
+		}
+	}
+
+	defer p.wakeOnDone(ctx)()
+
+	gen := p.enterWait()
+	defer p.leaveWait()
+
+	for !p.hasRoom() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		p.cond.Wait()
+		if err := p.waitCancelled(gen); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// hasRoom report whether the pool has an idle conn or room to dial, or is
+// released so waiting is pointless, p MUST be locked
+func (p *GRpcClientPool) hasRoom() bool {
+	return p.pool.size() > 0 || p.maxCount <= 0 || p.count-p.overflow < p.maxCount ||
+		p.overflow < p.maxOverflow || p.released()
+}
+
+// Max waits of Get with WithAggressiveDial for room to dial, and max duration
+// of each
+const (
+	aggressiveDialRetries = 3
+	aggressiveDialWait    = 10 * time.Millisecond
+)
+
+// WithAggressiveDial make Get take a slot freed by a connection retired
+// concurrently, e.g. reaped or failed, and dial rather than return
+// ERROR_MAX_CLIENT_COUNT for a transiently full pool. It waits briefly for
+// room, and again a few times if other Gets took the room first, unlike
+// GetWait which waits until ctx done.
+func WithAggressiveDial() Option {
+	return func(p *GRpcClientPool) {
+		p.aggressiveDial = true
+	}
+}
+
+// waitRoomBriefly is waitRoom for at most aggressiveDialWait, return
+// ERROR_MAX_CLIENT_COUNT if still full, p MUST be locked
+func (p *GRpcClientPool) waitRoomBriefly(ctx context.Context) error {
+	wctx, cancel := context.WithTimeout(ctx, aggressiveDialWait)
+	defer cancel()
+
+	if err := p.waitRoom(wctx); err != nil {
+		if ctx.Err() != nil || err != wctx.Err() {
+			return err
+		}
+		return ERROR_MAX_CLIENT_COUNT
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+func TestGetWaitMaxGetBlock(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute, WithMaxGetBlock(50*time.Millisecond))
+
+	c, _ := p.Get()
+	start := time.Now()
+	if _, err := p.GetWait(context.Background()); err != ERROR_GET_TIMEOUT {
+		t.Fatalf("want ERROR_GET_TIMEOUT, got %v", err)
+	}
+	if d := time.Since(start); d < 40*time.Millisecond || d > time.Second {
+		t.Fatalf("want blocked for the pool ceiling, got %v", d)
+	}
+
+	// a shorter ctx deadline wins
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if _, err := p.GetWait(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("want DeadlineExceeded, got %v", err)
+	}
+
+	p.Put(c)
+}
+
+func TestGetWaitFreed(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute, WithMaxGetBlock(time.Second))
+
+	c, _ := p.Get()
+	time.AfterFunc(10*time.Millisecond, func() { p.DelErrorClient(c) })
+	c2, err := p.GetWait(context.Background())
+	if err != nil {
+		t.Fatalf("want a dial once room freed, got %v", err)
+	}
+
+	time.AfterFunc(10*time.Millisecond, func() { p.Put(c2) })
+	if c3, err := p.GetWait(context.Background()); err != nil || c3 != c2 {
+		t.Fatalf("want the client given back, got %v", err)
+	}
+}
+
+func TestAggressiveDial(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 1, time.Minute, WithAggressiveDial())
+
+	c, _ := p.Get()
+	time.AfterFunc(5*time.Millisecond, func() { p.DelErrorClient(c) })
+	c2, err := p.Get()
+	if err != nil || c2 == c {
+		t.Fatalf("want a dial in the slot freed, got %v", err)
+	}
+
+	// still full, give up after a brief wait
+	start := time.Now()
+	if _, err := p.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+	if d := time.Since(start); d < aggressiveDialWait || d > time.Second {
+		t.Fatalf("want the brief wait, got %v", d)
+	}
+
+	// not waiting without the option
+	q := newTestPool(t, srv, 1, time.Minute)
+	c3, _ := q.Get()
+	time.AfterFunc(5*time.Millisecond, func() { q.DelErrorClient(c3) })
+	if _, err := q.Get(); err != ERROR_MAX_CLIENT_COUNT {
+		t.Fatalf("want ERROR_MAX_CLIENT_COUNT, got %v", err)
+	}
+}
+
+func TestCancelWaiters(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute)
+
+	c, _ := p.Get()
+	boom := errors.New("boom")
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		go func() {
+			_, err := p.GetWait(context.Background())
+			errs <- err
+		}()
+	}
+	waitUntil(t, func() bool { return p.WaiterCount() == 4 })
+
+	p.CancelWaiters(boom)
+	for i := 0; i < 4; i++ {
+		if err := <-errs; err != boom {
+			t.Fatalf("want the error given, got %v", err)
+		}
+	}
+	if n := p.WaiterCount(); n != 0 {
+		t.Fatalf("want no waiters left, got %v", n)
+	}
+
+	// the pool still works
+	time.AfterFunc(10*time.Millisecond, func() { p.Put(c) })
+	if _, err := p.GetWait(context.Background()); err != nil {
+		t.Fatalf("want the client given back, got %v", err)
+	}
+}
+
+func TestCancelWaitersPaused(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute, WithPauseBlocks())
+	p.Pause()
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := p.Get()
+		errs <- err
+	}()
+	waitUntil(t, func() bool { return p.WaiterCount() == 1 })
+
+	p.CancelWaiters(nil)
+	if err := <-errs; err != ERROR_WAIT_CANCELLED {
+		t.Fatalf("want ERROR_WAIT_CANCELLED, got %v", err)
+	}
+}
+
+func TestLoadShedding(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 1, time.Minute, WithLoadShedding(2))
+
+	c, _ := p.Get()
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			_, err := p.GetWait(ctx)
+			errs <- err
+		}()
+	}
+	waitUntil(t, func() bool { return p.WaiterCount() == 2 })
+
+	if _, err := p.GetWait(context.Background()); err != ERROR_OVERLOADED {
+		t.Fatalf("want ERROR_OVERLOADED, got %v", err)
+	}
+	// Do doesn't wait, failed fast as well
+	if err := p.Do(context.Background(), func(*grpc.ClientConn) error { return nil }); !IsCapacityError(err) {
+		t.Fatalf("want a capacity error, got %v", err)
+	}
+
+	cancel()
+	<-errs
+	<-errs
+	p.Put(c)
+	if d, err := p.GetWait(context.Background()); err != nil || d != c {
+		t.Fatalf("want the client given back, got %v", err)
+	}
+}
@@ -0,0 +1,63 @@
+package grpc_pool
+
+// WithLoadShedding make Gets return ERROR_OVERLOADED at once rather than
+// wait for room or a dial slot, when maxWaiters Gets are waiting already, to
+// keep latency bounded under overload. It applies to GetWait and Do.
+func WithLoadShedding(maxWaiters int) Option {
+	return func(p *GRpcClientPool) {
+		p.maxWaiters = maxWaiters
+	}
+}
+
+// shed return ERROR_OVERLOADED if no more Gets may wait, p MUST be locked
+func (p *GRpcClientPool) shed() error {
+	if p.maxWaiters > 0 && p.waiters >= p.maxWaiters {
+		return ERROR_OVERLOADED
+	}
+	return nil
+}
+
+// WaiterCount return the number of Gets blocked now, waiting for the pool
+// resumed, a dial slot or room to dial
+func (p *GRpcClientPool) WaiterCount() int {
+	p.Lock()
+	defer p.Unlock()
+
+	return p.waiters
+}
+
+// CancelWaiters wake up the Gets blocked now and make them return err, or
+// ERROR_WAIT_CANCELLED if err is nil, e.g. to unstick a service in an
+// incident. The pool is not closed, later Gets are not affected.
+func (p *GRpcClientPool) CancelWaiters(err error) {
+	if err == nil {
+		err = ERROR_WAIT_CANCELLED
+	}
+
+	p.Lock()
+	p.waitGen++
+	p.waitErr = err
+	p.cond.Broadcast()
+	p.Unlock()
+}
+
+// enterWait count a waiter and return the generation to check cancellation
+// by waitCancelled, call leaveWait when done. p MUST be locked.
+func (p *GRpcClientPool) enterWait() uint64 {
+	p.waiters++
+	return p.waitGen
+}
+
+// leaveWait uncount a waiter, p MUST be locked
+func (p *GRpcClientPool) leaveWait() {
+	p.waiters--
+}
+
+// waitCancelled return the error of CancelWaiters if called since gen, p MUST
+// be locked
+func (p *GRpcClientPool) waitCancelled(gen uint64) error {
+	if p.waitGen != gen {
+		return p.waitErr
+	}
+	return nil
+}
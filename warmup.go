@@ -0,0 +1,165 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// Max concurrent dials of a Warmup, and max pools warmed at once by
+// MapPool.WarmupAll
+const (
+	warmupWorkers = 4
+	warmupPools   = 8
+)
+
+// Max duration of WithEagerDial unless a dial timeout set
+const defaultEagerDialTimeout = 10 * time.Second
+
+// Warmup dial connections until the pool has MinIdle idle connections, see
+// WithMinIdle. Connections idle or being dialed by another Warmup are
+// counted, so calling it repeatedly, e.g. on a ticker, only tops up.
+func (p *GRpcClientPool) Warmup(ctx context.Context) error {
+	return p.warmup(ctx, p.minIdle)
+}
+
+// WarmupAll create the pools of addrs and dial perAddr idle connections in
+// each concurrently, e.g. before accepting traffic at startup. Connections
+// are bounded by maxCount of each pool, and pools by WithMaxPools. Errors of
+// all addresses are joined.
+func (mp *MapPool) WarmupAll(ctx context.Context, addrs []string, perAddr int) error {
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, warmupPools)
+		errs = make([]error, len(addrs))
+	)
+	for i, addr := range addrs {
+		wg.Add(1)
+		go func(i int, addr string) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			if err := mp.GetPool(addr).warmup(ctx, perAddr); err != nil {
+				errs[i] = fmt.Errorf("Warmup addr[%v] failed: %w", addr, err)
+			}
+		}(i, addr)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// WithEagerDial make the pool dial n connections when created and wait them
+// ready, so a backend unreachable is found at startup. NewGRpcClientPoolE
+// fails if any can't, connections dialed go into the idle pool. See Start.
+func WithEagerDial(n int) Option {
+	return func(p *GRpcClientPool) {
+		p.eagerDial = n
+	}
+}
+
+// Start dial connections set by WithEagerDial, until n are idle, and wait
+// them ready, connections not ready are evicted and the first failure
+// returned in a DialError.
+func (p *GRpcClientPool) Start(ctx context.Context) error {
+	if err := p.warmup(ctx, p.eagerDial); err != nil {
+		return err
+	}
+
+	p.Lock()
+	idle := p.idleClients()
+	p.Unlock()
+
+	var first error
+	for _, c := range idle {
+		if err := waitReady(ctx, c, true); err != nil {
+			p.Evict(c)
+			if first == nil {
+				first = &DialError{Addr: c.addr, Err: err}
+			}
+		}
+	}
+
+	return first
+}
+
+// eagerDialTimeout bound dials of WithEagerDial by the dial timeout if set
+func (p *GRpcClientPool) eagerDialTimeout() time.Duration {
+	if p.dialTimeout > 0 {
+		return p.dialTimeout
+	}
+	return defaultEagerDialTimeout
+}
+
+// warmup top up idle connections to n without exceeding maxCount, each dial
+// is subject to the quota hook as Get
+func (p *GRpcClientPool) warmup(ctx context.Context, n int) error {
+	p.Lock()
+	if p.released() {
+		p.Unlock()
+		return ERROR_POOL_CLOSED
+	}
+	if p.draining {
+		p.Unlock()
+		return ERROR_POOL_DRAINING
+	}
+	if p.fdBlocked() {
+		p.Unlock()
+		return ERROR_FD_EXHAUSTED
+	}
+	need := n - p.pool.size() - p.warming
+	if p.maxCount > 0 && need > p.maxCount-p.count+p.overflow {
+		need = p.maxCount - p.count + p.overflow
+	}
+	if need <= 0 {
+		p.Unlock()
+		return nil
+	}
+
+	p.addCount(need)
+	p.warming += need
+	calls := make([]*dialCall, need)
+	for i := range calls {
+		calls[i] = p.startDial()
+	}
+	p.Unlock()
+
+	var (
+		wg   sync.WaitGroup
+		sem  = make(chan struct{}, warmupWorkers)
+		errs = make([]error, need)
+	)
+	for i, call := range calls {
+		wg.Add(1)
+		go func(i int, call *dialCall) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			if err := p.askQuota(); err != nil {
+				p.abandonDial(call, err)
+				errs[i] = err
+			} else {
+				_, errs[i] = p.dial(ctx, call, false)
+			}
+			<-sem
+		}(i, call)
+	}
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// abandonDial give up a warmup dial before started, freeing its place
+func (p *GRpcClientPool) abandonDial(call *dialCall, err error) {
+	p.Lock()
+	delete(p.dialing, call)
+	p.warming--
+	p.uncountDial(call)
+	p.Unlock()
+
+	call.err = err
+	close(call.done)
+}
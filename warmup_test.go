@@ -0,0 +1,169 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/SongLiangChen/grpc_pool/testutil"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+// countingDial return a slow dial func of srv and the dials counter
+func countingDial(srv *testutil.Server) (DialFunc, *atomic.Int32) {
+	var dials atomic.Int32
+	dialF := srv.DialFunc()
+	return func(addr string) (*grpc.ClientConn, error) {
+		dials.Add(1)
+		time.Sleep(10 * time.Millisecond)
+		return dialF(addr)
+	}, &dials
+}
+
+func TestWarmupTopUp(t *testing.T) {
+	dialF, dials := countingDial(newTestServer(t))
+	p, _ := NewGRpcClientPoolE("bufnet", dialF, 5, time.Minute, WithMinIdle(3))
+	defer p.Release()
+
+	go p.Warmup(context.Background())
+	if err := p.Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	p.WaitWarm(context.Background(), 3)
+	if err := p.Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n := dials.Load(); n != 3 || p.Stats().Idle != 3 {
+		t.Fatalf("want 3 dials for MinIdle, got %v, %+v", n, p.Stats())
+	}
+}
+
+func TestWarmupBoundedByMaxCount(t *testing.T) {
+	dialF, _ := countingDial(newTestServer(t))
+	p, _ := NewGRpcClientPoolE("bufnet", dialF, 2, time.Minute, WithMinIdle(3))
+	defer p.Release()
+
+	p.Warmup(context.Background())
+	if s := p.Stats(); s.Count != 2 {
+		t.Fatalf("want maxCount dialed, got %+v", s)
+	}
+}
+
+func TestGetNoDialJoinWarmup(t *testing.T) {
+	dialF, _ := countingDial(newTestServer(t))
+	p, _ := NewGRpcClientPoolE("bufnet", dialF, 2, time.Minute, WithMinIdle(1))
+	defer p.Release()
+
+	go p.Warmup(context.Background())
+	waitUntil(t, func() bool { return p.Stats().Count == 1 })
+	if _, err := p.GetNoDial(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWarmupRPC(t *testing.T) {
+	var rpcs atomic.Int32
+	warm := func(ctx context.Context, conn *grpc.ClientConn) error {
+		rpcs.Add(1)
+		_, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{})
+		return err
+	}
+	p := newTestPool(t, newTestServer(t), 10, time.Minute, WithMinIdle(3), WithWarmupRPC(warm))
+
+	if err := p.Warmup(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if n, s := rpcs.Load(), p.Stats(); n != 3 || s.Dials != 3 {
+		t.Fatalf("want a warmup rpc per connection, got %v for %+v", n, s)
+	}
+
+	// not for connections dialed by Get
+	p.Get()
+	p.Get()
+	p.Get()
+	p.Get()
+	if n := rpcs.Load(); n != 3 {
+		t.Fatalf("want no warmup rpc on Get, got %v", n)
+	}
+}
+
+func TestWarmupRPCFailed(t *testing.T) {
+	cold := func(context.Context, *grpc.ClientConn) error { return errors.New("cold") }
+	p := newTestPool(t, newTestServer(t), 10, time.Minute, WithMinIdle(2), WithWarmupRPC(cold))
+
+	if err := p.Warmup(context.Background()); err == nil {
+		t.Fatal("want the warmup rpc error")
+	}
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the connections discarded, got %+v", s)
+	}
+}
+
+func TestEagerDial(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute, WithEagerDial(2))
+	if s := p.Stats(); s.Idle != 2 || s.Count != 2 {
+		t.Fatalf("want 2 dialed when created, got %+v", s)
+	}
+}
+
+func TestEagerDialDown(t *testing.T) {
+	srv := newTestServer(t)
+	down := func(context.Context, string) (net.Conn, error) { return nil, errors.New("server down") }
+
+	start := time.Now()
+	_, err := NewGRpcClientPoolE("bufnet", srv.DialFunc(grpc.WithContextDialer(down)), 5, time.Minute, WithEagerDial(2))
+	if !IsDialError(err) {
+		t.Fatalf("want a DialError, got %v", err)
+	}
+	if d := time.Since(start); d > 5*time.Second {
+		t.Fatalf("want failed fast, got %v", d)
+	}
+}
+
+func TestEagerDialMapPoolShared(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 2, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	// pools are created without the lock, only one of them kept
+	var wg sync.WaitGroup
+	ps := make([]*GRpcClientPool, 8)
+	for i := range ps {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ps[i] = mp.GetPool("bufnet")
+		}(i)
+	}
+	wg.Wait()
+	for _, p := range ps {
+		if p != ps[0] {
+			t.Fatal("want the same pool for all")
+		}
+	}
+}
+
+func TestWarmupAll(t *testing.T) {
+	mp := NewMapPool(newTestServer(t).DialFunc(), 3, time.Minute)
+	defer mp.ReleaseAllPool()
+
+	// bounded by maxCount
+	addrs := []string{"a", "b"}
+	if err := mp.WarmupAll(context.Background(), addrs, 5); err != nil {
+		t.Fatal(err)
+	}
+	for _, addr := range addrs {
+		if s := mp.GetPool(addr).Stats(); s.Idle != 3 {
+			t.Fatalf("want %v warmed to maxCount, got %+v", addr, s)
+		}
+	}
+
+	mp.MarkDraining("a")
+	if err := mp.WarmupAll(context.Background(), addrs, 5); !errors.Is(err, ERROR_POOL_DRAINING) {
+		t.Fatalf("want the error of a, got %v", err)
+	}
+}
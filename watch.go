@@ -0,0 +1,79 @@
+package grpc_pool
+
+import (
+	"context"
+
+	"google.golang.org/grpc/connectivity"
+)
+
+// WithStateWatching watch the state of each connection and drain it from the
+// pool once it goes from Ready to Idle, which is how gRPC reacts to a GOAWAY
+// from the server, or into TransientFailure or Shutdown. Idle connections
+// are closed right away and checked out ones when given back. Draining on
+// GOAWAY needs this option, without it such connections are only noticed
+// when validated on Put.
+func WithStateWatching() Option {
+	return func(p *GRpcClientPool) {
+		p.watchState = true
+	}
+}
+
+// startWatch watch c in background unless the pool released, p MUST be
+// locked
+func (p *GRpcClientPool) startWatch(c *IdleClient) {
+	if p.released() {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	c.unwatch = cancel
+	p.background(func() {
+		defer cancel()
+		p.watch(ctx, c)
+	})
+}
+
+// stopWatch stop watching c, e.g. as it moves to another pool, p MUST be
+// locked
+func (p *GRpcClientPool) stopWatch(c *IdleClient) {
+	if c.unwatch != nil {
+		c.unwatch()
+		c.unwatch = nil
+	}
+}
+
+// watch wait state changes of c until it should be drained, ctx canceled or
+// the pool released
+func (p *GRpcClientPool) watch(ctx context.Context, c *IdleClient) {
+	prev, state := connectivity.Idle, c.conn.GetState()
+	for {
+		switch {
+		case state == connectivity.TransientFailure, state == connectivity.Shutdown,
+			prev == connectivity.Ready && state == connectivity.Idle:
+			p.Evict(c)
+			return
+		}
+
+		if !c.conn.WaitForStateChange(ctx, state) {
+			return
+		}
+		prev, state = state, c.conn.GetState()
+	}
+}
+
+// StateBreakdown return the number of connections in each state, idle and
+// checked out ones, e.g. many in TransientFailure reveal a flapping backend
+func (p *GRpcClientPool) StateBreakdown() map[connectivity.State]int {
+	p.Lock()
+	defer p.Unlock()
+
+	states := make(map[connectivity.State]int)
+	for _, c := range p.idleClients() {
+		states[c.conn.GetState()]++
+	}
+	for c := range p.out {
+		states[c.conn.GetState()]++
+	}
+
+	return states
+}
@@ -0,0 +1,74 @@
+package grpc_pool
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+)
+
+func TestStateWatchingGoAway(t *testing.T) {
+	srv := newTestServer(t)
+	p := newTestPool(t, srv, 5, time.Minute, WithStateWatching())
+
+	out, _ := p.Get()
+	idle, _ := p.Get()
+	for _, c := range []*IdleClient{out, idle} {
+		if err := checkHealth(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	p.Put(idle)
+
+	// the server send GOAWAY on graceful stop
+	srv.GracefulStop()
+	waitUntil(t, func() bool { return p.Stats().Idle == 0 })
+
+	if s := p.Stats(); s.Count != 1 {
+		t.Fatalf("want the idle client drained, got %+v", s)
+	}
+	p.Lock()
+	closed, retire := idle.closed, out.retireOnReturn
+	p.Unlock()
+	if !closed || !retire {
+		t.Fatalf("want the idle client closed and the checked out one flagged, got %v and %v", closed, retire)
+	}
+
+	p.Put(out)
+	if s := p.Stats(); s.Count != 0 {
+		t.Fatalf("want the flagged client retired on Put, got %+v", s)
+	}
+}
+
+func TestStateBreakdown(t *testing.T) {
+	p := newTestPool(t, newTestServer(t), 5, time.Minute)
+
+	a, _ := p.Get()
+	b, _ := p.Get()
+	c, _ := p.Get()
+	if err := checkHealth(a); err != nil {
+		t.Fatal(err)
+	}
+	c.GetConn().Close()
+	p.Put(b)
+
+	// checked out ones as well
+	states := p.StateBreakdown()
+	if states[connectivity.Ready] != 1 || states[connectivity.Idle] != 1 || states[connectivity.Shutdown] != 1 {
+		t.Fatalf("want one Ready, one Idle and one Shutdown, got %v", states)
+	}
+}
+
+func TestStateBreakdownTransientFailure(t *testing.T) {
+	srv := newTestServer(t)
+	down := func(context.Context, string) (net.Conn, error) { return nil, errors.New("server down") }
+	p := newTestPool(t, srv, 5, time.Minute, WithDialOptionsFunc(srv.DialOptionsFunc()), WithDialOptions(grpc.WithContextDialer(down)))
+
+	c, _ := p.Get()
+	c.GetConn().Connect()
+	waitUntil(t, func() bool { return p.StateBreakdown()[connectivity.TransientFailure] == 1 })
+}